@@ -17,6 +17,9 @@
 package main
 
 import (
+	// 必须在chirpstack v3/v4的api包之前初始化，见internal/protoconflict
+	_ "github.com/edgexfoundry/device-lora-go/internal/protoconflict"
+
 	"github.com/edgexfoundry/device-sdk-go/v3/pkg/startup"
 
 	device_lora "github.com/edgexfoundry/device-lora-go"
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestProtoRegistration builds the service and runs it without a registration conflict policy in the environment,
+// the binary panics at startup when the ChirpStack v3 and v4 API packages are initialized before protoconflict
+func TestProtoRegistration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds the service")
+	}
+
+	binary := filepath.Join(t.TempDir(), "device-lora")
+	build := exec.Command("go", "build", "-o", binary, ".")
+	if output, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("build failed: %v\n%s", err, output)
+	}
+
+	var env []string
+	for _, value := range os.Environ() {
+		if !strings.HasPrefix(value, "GOLANG_PROTOBUF_REGISTRATION_CONFLICT=") {
+			env = append(env, value)
+		}
+	}
+	run := exec.Command(binary, "-h")
+	run.Env = env
+	output, err := run.CombinedOutput()
+	if err != nil {
+		t.Fatalf("service failed at startup: %v\n%s", err, output)
+	}
+	if strings.Contains(string(output), "conflict") {
+		t.Errorf("unexpected registration conflict:\n%s", output)
+	}
+}
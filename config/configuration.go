@@ -1,17 +1,85 @@
 package config
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
 
 type ServiceConfig struct {
 	ChirpStack ChirpStackConfig
 }
 
 type ChirpStackConfig struct {
-	Version     string
-	Host        string
-	Username    string
-	Password    string
-	ActivateKey string
+	// Version selects the ChirpStack API at startup, V3 or V4
+	Version string
+	Host    string
+	// SecretName is the secret which holds the username, password (or v4 apiToken) and activateKey of ChirpStack
+	SecretName string
+	// ApiToken is the v4 API token used when the secret has no apiToken, the secret is optional then
+	ApiToken string
+	// TenantId (v4) or OrganizationId (v3), ApplicationId and NetworkServerId (v3) select where the devices are
+	// registered, they are verified at startup and TenantId is required for tenant API tokens. When an id is empty
+	// the one of the name is used, or the only one when the name is empty too.
+	TenantId         string
+	TenantName       string
+	OrganizationId   int64
+	OrganizationName string
+	// ApplicationId is a number for v3
+	ApplicationId     string
+	ApplicationName   string
+	NetworkServerId   int64
+	NetworkServerName string
+	// AutoCreate creates the missing tenant or organization, application and network server with the names,
+	// the network server also needs its NetworkServerAddress (host:port)
+	AutoCreate           bool
+	NetworkServerAddress string
+	// UseTLS connects to ChirpStack over TLS, CAFile is the PEM CA bundle (empty: system roots) and
+	// CertFile/KeyFile the PEM client certificate for mutual TLS
+	UseTLS   bool
+	CAFile   string
+	CertFile string
+	KeyFile  string
+	// ServerName overrides the host name verified in the ChirpStack certificate
+	ServerName string
+	// TLSSecretName is the secret which holds the PEM caCert, clientCert and clientKey, it replaces the files
+	TLSSecretName string
+	// ProfileMapping maps ChirpStack device profile names to EdgeX device profile names for discovery,
+	// unmapped profiles keep their name
+	ProfileMapping map[string]string
+	// ProfileDefaults are the LoRaWAN settings of the ChirpStack device profiles which the codec resource of the
+	// EdgeX device profile doesn't set
+	ProfileDefaults ProfileDefaults
+	// ProfileSyncInterval is how often the EdgeX device profiles are compared with the settings pushed to ChirpStack
+	// and unused ChirpStack profiles are cleaned up, e.g. 1m, empty or 0 only does it at startup
+	ProfileSyncInterval string
+	// ReconcileInterval is how often EdgeX devices are reconciled with ChirpStack, e.g. 1h, empty or 0 disables it
+	ReconcileInterval string
+	// OrphanPolicy is report (default) or delete, orphans are ChirpStack devices and gateways which aren't EdgeX devices,
+	// orphan gateways are always only reported
+	OrphanPolicy string
+	// DownlinkTimeout is how long a confirmed downlink waits for the device ack, e.g. 30m
+	DownlinkTimeout string
+	// ReadingMaxAge is how old the last uplink can be to serve reads, e.g. 1h, empty or 0 means no limit
+	ReadingMaxAge string
+}
+
+// ProfileDefaults holds the default LoRaWAN settings of the ChirpStack device profiles, empty settings use
+// CN470, LoRaWAN 1.0.2, regional parameters A, a 10m uplink interval, a max EIRP of 20 and the default ADR algorithm
+type ProfileDefaults struct {
+	Region            string
+	MacVersion        string
+	RegParamsRevision string
+	// UplinkInterval is the expected uplink interval of the devices, e.g. 10m
+	UplinkInterval string
+	// MaxEirp is used by v3, the RX settings of ABP devices by v4
+	MaxEirp        uint32
+	Rx1Delay       uint32
+	Rx2DataRate    uint32
+	Rx2Frequency   uint32
+	AdrAlgorithmId string
 }
 
 func (sw *ServiceConfig) UpdateFromRaw(rawConfig interface{}) bool {
@@ -30,20 +98,60 @@ func (scc *ChirpStackConfig) Validate() error {
 		return errors.New("ChirpStack.Version configuration setting can not be blank")
 	}
 
+	if version := strings.ToUpper(scc.Version); version != "V3" && version != "V4" {
+		return fmt.Errorf("ChirpStack.Version configuration setting %s is invalid, expected V3 or V4", scc.Version)
+	}
+
+	if strings.ToUpper(scc.Version) == "V3" && len(scc.ApplicationId) > 0 {
+		if _, err := strconv.ParseInt(scc.ApplicationId, 10, 64); err != nil {
+			return fmt.Errorf("ChirpStack.ApplicationId configuration setting %s is invalid, expected a number for V3", scc.ApplicationId)
+		}
+	}
+
 	if len(scc.Host) == 0 {
 		return errors.New("ChirpStack.Host configuration setting can not be blank")
 	}
 
-	if len(scc.Username) == 0 {
-		return errors.New("ChirpStack.Username configuration setting can not be blank")
+	if len(scc.SecretName) == 0 {
+		return errors.New("ChirpStack.SecretName configuration setting can not be blank")
+	}
+
+	if len(scc.CertFile) > 0 != (len(scc.KeyFile) > 0) {
+		return errors.New("ChirpStack.CertFile and ChirpStack.KeyFile configuration settings must be set together")
+	}
+
+	if len(scc.DownlinkTimeout) > 0 {
+		if _, err := time.ParseDuration(scc.DownlinkTimeout); err != nil {
+			return fmt.Errorf("ChirpStack.DownlinkTimeout configuration setting is invalid: %s", err.Error())
+		}
+	}
+
+	if len(scc.ProfileDefaults.UplinkInterval) > 0 {
+		if _, err := time.ParseDuration(scc.ProfileDefaults.UplinkInterval); err != nil {
+			return fmt.Errorf("ChirpStack.ProfileDefaults.UplinkInterval configuration setting is invalid: %s", err.Error())
+		}
+	}
+
+	if len(scc.ProfileSyncInterval) > 0 {
+		if _, err := time.ParseDuration(scc.ProfileSyncInterval); err != nil {
+			return fmt.Errorf("ChirpStack.ProfileSyncInterval configuration setting is invalid: %s", err.Error())
+		}
+	}
+
+	if len(scc.ReconcileInterval) > 0 {
+		if _, err := time.ParseDuration(scc.ReconcileInterval); err != nil {
+			return fmt.Errorf("ChirpStack.ReconcileInterval configuration setting is invalid: %s", err.Error())
+		}
 	}
 
-	if len(scc.Password) == 0 {
-		return errors.New("ChirpStack.Password configuration setting can not be blank")
+	if policy := strings.ToLower(scc.OrphanPolicy); policy != "" && policy != "report" && policy != "delete" {
+		return fmt.Errorf("ChirpStack.OrphanPolicy configuration setting %s is invalid, expected report or delete", scc.OrphanPolicy)
 	}
 
-	if len(scc.ActivateKey) == 0 {
-		return errors.New("ChirpStack.ActivateKey configuration setting can not be blank")
+	if len(scc.ReadingMaxAge) > 0 {
+		if _, err := time.ParseDuration(scc.ReadingMaxAge); err != nil {
+			return fmt.Errorf("ChirpStack.ReadingMaxAge configuration setting is invalid: %s", err.Error())
+		}
 	}
 
 	return nil
@@ -0,0 +1,68 @@
+package driver
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	sdkModels "github.com/edgexfoundry/device-sdk-go/v3/pkg/models"
+)
+
+// ReadingCache keeps the latest uplink reading of every device resource, class A devices can't be polled,
+// so reads are served from the last known uplink
+type ReadingCache struct {
+	mutex    sync.RWMutex
+	readings map[string]map[string]sdkModels.CommandValue
+}
+
+func NewReadingCache() *ReadingCache {
+	return &ReadingCache{
+		readings: make(map[string]map[string]sdkModels.CommandValue),
+	}
+}
+
+// Update records the readings of a device uplink
+func (c *ReadingCache) Update(deviceName string, values []*sdkModels.CommandValue) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	readings, ok := c.readings[deviceName]
+	if !ok {
+		readings = make(map[string]sdkModels.CommandValue)
+		c.readings[deviceName] = readings
+	}
+	for _, value := range values {
+		if value != nil {
+			readings[value.DeviceResourceName] = *value
+		}
+	}
+}
+
+// Get returns the latest reading of the device resource with its original timestamp,
+// a reading older than maxAge is stale, maxAge 0 means readings never get stale
+func (c *ReadingCache) Get(deviceName string, resourceName string, maxAge time.Duration) (*sdkModels.CommandValue, error) {
+	c.mutex.RLock()
+	value, ok := c.readings[deviceName][resourceName]
+	c.mutex.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("no uplink of device %s resource %s received yet", deviceName, resourceName)
+	}
+
+	if maxAge > 0 {
+		origin := time.Unix(0, value.Origin)
+		if age := time.Since(origin); age > maxAge {
+			return nil, fmt.Errorf("reading of device %s resource %s is stale: last uplink at %s, %s ago exceeds max age %s",
+				deviceName, resourceName, origin.Format(time.RFC3339), age.Truncate(time.Second), maxAge)
+		}
+	}
+
+	return &value, nil
+}
+
+// Remove drops all readings of a device
+func (c *ReadingCache) Remove(deviceName string) {
+	c.mutex.Lock()
+	delete(c.readings, deviceName)
+	c.mutex.Unlock()
+}
@@ -0,0 +1,63 @@
+package driver
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	sdkModels "github.com/edgexfoundry/device-sdk-go/v3/pkg/models"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/common"
+)
+
+func TestReadingCache(t *testing.T) {
+	cache := NewReadingCache()
+
+	fresh, _ := sdkModels.NewCommandValue("temperature", common.ValueTypeFloat32, float32(21.5))
+	fresh.Origin = time.Now().Add(-time.Minute).UnixNano()
+	old, _ := sdkModels.NewCommandValue("humidity", common.ValueTypeFloat32, float32(40))
+	old.Origin = time.Now().Add(-2 * time.Hour).UnixNano()
+	cache.Update("sensor", []*sdkModels.CommandValue{fresh, old})
+
+	tests := []struct {
+		name     string
+		device   string
+		resource string
+		maxAge   time.Duration
+		err      string
+	}{
+		{"fresh reading", "sensor", "temperature", time.Hour, ""},
+		{"stale reading", "sensor", "humidity", time.Hour, "stale"},
+		{"no max age", "sensor", "humidity", 0, ""},
+		{"unknown resource", "sensor", "co2", time.Hour, "no uplink"},
+		{"unknown device", "other", "temperature", time.Hour, "no uplink"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := cache.Get(tt.device, tt.resource, tt.maxAge)
+			if len(tt.err) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expected %s error, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if value.DeviceResourceName != tt.resource {
+				t.Errorf("expected resource %s, got %s", tt.resource, value.DeviceResourceName)
+			}
+		})
+	}
+
+	// the original uplink timestamp is kept
+	value, _ := cache.Get("sensor", "temperature", 0)
+	if value.Origin != fresh.Origin {
+		t.Errorf("expected origin %d, got %d", fresh.Origin, value.Origin)
+	}
+
+	cache.Remove("sensor")
+	if _, err := cache.Get("sensor", "temperature", 0); err == nil {
+		t.Errorf("expected error after remove")
+	}
+}
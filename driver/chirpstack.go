@@ -0,0 +1,155 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/edgexfoundry/device-lora-go/config"
+	// 注册冲突策略需要在chirpstack v3/v4的api包之前初始化
+	"github.com/edgexfoundry/device-lora-go/internal/protoconflict"
+	"google.golang.org/grpc"
+)
+
+func init() {
+	// v3/v4的api包已在driver包之前初始化，之后的注册冲突恢复为默认的panic
+	protoconflict.Restore()
+}
+
+const (
+	// ChirpStack API versions of ChirpStackConfig.Version
+	VersionV3 = "V3"
+	VersionV4 = "V4"
+
+	// Device event types
+	EventUp    = "up"
+	EventAck   = "ack"
+	EventTxAck = "txack"
+)
+
+// ChirpStack is the API of a ChirpStack server, the implementation of the configured version is selected at startup
+type ChirpStack interface {
+	Init() error
+	// Login makes sure the session is valid and returns the context of the following calls
+	Login() (context.Context, error)
+	Credentials() Credentials
+	SetCredentials(credentials Credentials)
+
+	// CreateProfile returns the device profile of the name, it is created with the LoRaWAN settings when it doesn't exist
+	CreateProfile(ctx context.Context, name string, params LoraProfileParams) (id string, err error)
+	// UpdateProfile pushes the codec and LoRaWAN settings to the device profile of the name when they differ,
+	// updated is false when they are the same or the profile doesn't exist
+	UpdateProfile(ctx context.Context, name string, params LoraProfileParams) (updated bool, err error)
+	// DeleteProfile deletes the device profile of the exact name
+	DeleteProfile(ctx context.Context, name string) error
+	// FindProfile returns the id of the device profile of the exact name, it is empty when the profile doesn't exist
+	FindProfile(ctx context.Context, name string) (id string, err error)
+	// ProfileInUse reports whether devices of any application of the tenant or organization use the device profile
+	// of the id, ChirpStack profiles aren't limited to the application
+	ProfileInUse(ctx context.Context, profileId string) (bool, error)
+	CreateGateway(ctx context.Context, gateWayId string, name string) error
+	UpdateGateway(ctx context.Context, gateWayId string, name string) error
+	DeleteGateway(ctx context.Context, gateWayId string) error
+	// CreateDevice and UpdateDevice disable the device in ChirpStack when it is locked in EdgeX
+	CreateDevice(ctx context.Context, DevEUI string, name string, deviceProfileId string, disabled bool) error
+	ActivateDevice(ctx context.Context, DevEUI string, devAddr string, appSKey string, nwkSEncKey string, sNwkSIntKey string, fNwkSIntKey string) error
+	CreateKeys(ctx context.Context, DevEUI string, appKey string, nwkKey string) error
+	// UpdateDevice renames a device, an empty deviceProfileId keeps its device profile
+	UpdateDevice(ctx context.Context, DevEUI string, name string, deviceProfileId string, disabled bool) error
+	// HasKeys reports whether the root keys of an OTAA device are set and HasActivation whether an ABP device is activated
+	HasKeys(ctx context.Context, DevEUI string) (bool, error)
+	HasActivation(ctx context.Context, DevEUI string) (bool, error)
+	DeleteDevice(ctx context.Context, deviceName string, DevEUI string) error
+	DeviceExists(ctx context.Context, DevEUI string) (bool, error)
+	// ListDevices returns the devices of the application and ListGateways the gateways of the tenant or organization
+	ListDevices(ctx context.Context) ([]DeviceInfo, error)
+	ListGateways(ctx context.Context) ([]GatewayInfo, error)
+
+	Enqueue(ctx context.Context, DevEUI string, fPort uint32, confirmed bool, data []byte, object string) (id string, err error)
+	ListQueue(ctx context.Context, DevEUI string) ([]QueueItem, error)
+	CountQueue(ctx context.Context, DevEUI string) (uint32, error)
+	FlushQueue(ctx context.Context, DevEUI string) error
+
+	// StreamEvents opens the event stream of a device, receive blocks until the next event or the stream fails
+	StreamEvents(ctx context.Context, DevEUI string) (receive func() (*DeviceEvent, error), err error)
+}
+
+// DeviceEvent is an event of the device event stream decoded from the payload of the ChirpStack version
+type DeviceEvent struct {
+	// Type is up, ack or txack, other events are skipped
+	Type string
+	// Object is the codec output of an uplink
+	Object   map[string]interface{}
+	Metadata *RadioMetadata
+	// Time is when ChirpStack received the uplink, zero when unknown
+	Time time.Time
+	// DownlinkId identifies the downlink of an ack or txack, Acknowledged is the ack result
+	DownlinkId   string
+	Acknowledged bool
+	// Payload is the raw event for logging
+	Payload string
+	// Err is set when the payload can't be decoded
+	Err error
+}
+
+// DeviceInfo is a device registered in ChirpStack
+type DeviceInfo struct {
+	DevEUI      string
+	Name        string
+	Description string
+	// ProfileName is the ChirpStack device profile, SupportsJoin is whether the profile activates the devices by OTAA
+	ProfileName  string
+	SupportsJoin bool
+}
+
+// GatewayInfo is a gateway registered in ChirpStack
+type GatewayInfo struct {
+	GatewayId   string
+	Name        string
+	Description string
+}
+
+// NewChirpStack returns the ChirpStack client of the configured version, store is nil for plain text connections
+func NewChirpStack(chirpConfig config.ChirpStackConfig, store *TLSStore) (ChirpStack, error) {
+	switch strings.ToUpper(chirpConfig.Version) {
+	case VersionV3:
+		return &ChirpStackV3{chirpStackClient: chirpStackClient{config: chirpConfig, tlsStore: store}}, nil
+	case VersionV4:
+		return &ChirpStackV4{chirpStackClient: chirpStackClient{config: chirpConfig, tlsStore: store}}, nil
+	}
+	return nil, fmt.Errorf("ChirpStack version %s is not supported, expected %s or %s", chirpConfig.Version, VersionV3, VersionV4)
+}
+
+// chirpStackClient is the connection and session shared by the ChirpStack versions
+type chirpStackClient struct {
+	conn   *grpc.ClientConn
+	config config.ChirpStackConfig
+	CredentialStore
+	session  *Session
+	tlsStore *TLSStore
+}
+
+// dial connects to ChirpStack, the jwt or API token is sent with every RPC and a rejected jwt is refreshed once
+func (c *chirpStackClient) dial(login LoginFunc) (err error) {
+	c.session = NewSession(&c.CredentialStore, login)
+	c.conn, err = grpc.Dial(c.config.Host, transportOption(c.tlsStore), grpc.WithPerRPCCredentials(c.session), grpc.WithUnaryInterceptor(c.session.UnaryInterceptor))
+	return
+}
+
+// Login makes sure the session is valid, ChirpStack is only logged in when the jwt is missing or about to expire.
+// API token mode never logs in.
+func (c *chirpStackClient) Login() (ctx context.Context, err error) {
+	if err = c.session.Refresh(); err != nil {
+		return nil, err
+	}
+	return context.Background(), nil
+}
+
+// SetCredentials replaces the credentials, the session logs in again with them
+func (c *chirpStackClient) SetCredentials(credentials Credentials) {
+	c.CredentialStore.SetCredentials(credentials)
+	if c.session != nil {
+		c.session.Invalidate()
+	}
+}
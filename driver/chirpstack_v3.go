@@ -1,81 +1,254 @@
-//go:build chirpstack3
-// +build chirpstack3
-
 package driver
 
 import (
 	"context"
+	"strconv"
 
-	"github.com/edgexfoundry/device-lora-go/config"
+	"github.com/brocaar/chirpstack-api/go/v3/as/external/api"
 	v3 "github.com/edgexfoundry/device-lora-go/utils/v3"
-	"google.golang.org/grpc"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
 )
 
 var (
 	Limit64 int64 = 100
 )
 
-type ChirpStack struct {
-	conn            *grpc.ClientConn
-	config          config.ChirpStackConfig
+type ChirpStackV3 struct {
+	chirpStackClient
 	NetWorkServerId int64
 	OrganizationId  int64
 	ApplicationId   int64
 }
 
-func (c *ChirpStack) Init() (err error) {
-	c.conn, err = grpc.Dial(c.config.Host, grpc.WithInsecure())
+func (c *ChirpStackV3) Init() (err error) {
+	if err = c.dial(func(username string, password string) (string, error) {
+		return v3.Login(c.conn, username, password)
+	}); err != nil {
+		return
+	}
+
+	var ctx context.Context
+	if ctx, err = c.Login(); err != nil {
+		return
+	}
+	// ApplicationId已在配置校验时检查为数字
+	applicationId, _ := strconv.ParseInt(c.config.ApplicationId, 10, 64)
+	c.NetWorkServerId, c.OrganizationId, c.ApplicationId, err = v3.Init(c.conn, ctx, v3.Scope{
+		NetworkServerId:      c.config.NetworkServerId,
+		NetworkServerName:    c.config.NetworkServerName,
+		NetworkServerAddress: c.config.NetworkServerAddress,
+		OrganizationId:       c.config.OrganizationId,
+		OrganizationName:     c.config.OrganizationName,
+		ApplicationId:        applicationId,
+		ApplicationName:      c.config.ApplicationName,
+		AutoCreate:           c.config.AutoCreate,
+	})
+	return
+}
 
-	c.NetWorkServerId, c.OrganizationId, c.ApplicationId, err = v3.Init(c.conn, c.config)
+func (c *ChirpStackV3) CreateProfile(ctx context.Context, name string, params LoraProfileParams) (id string, err error) {
+	id, err = v3.CreateProfile(c.conn, ctx, c.NetWorkServerId, c.OrganizationId, c.ApplicationId, profileV3(name, params))
 	return
 }
 
-func (c *ChirpStack) Login() (ctx context.Context, err error) {
-	ctx, err = v3.Login(c.conn, c.config.Username, c.config.Password)
+// UpdateProfile updates the device profile of the name when its codec or LoRaWAN settings differ, the other settings
+// of the profile are kept
+func (c *ChirpStackV3) UpdateProfile(ctx context.Context, name string, params LoraProfileParams) (updated bool, err error) {
+	var id string
+	if id, err = v3.FindProfile(c.conn, ctx, c.OrganizationId, c.ApplicationId, name); err != nil || len(id) == 0 {
+		return
+	}
+	var current *api.DeviceProfile
+	if current, err = v3.GetProfile(c.conn, ctx, id); err != nil {
+		return
+	}
+
+	profile := proto.Clone(current).(*api.DeviceProfile)
+	applyProfileV3(profile, params)
+	if proto.Equal(profile, current) {
+		return
+	}
+	if err = v3.UpdateProfile(c.conn, ctx, profile); err != nil {
+		return
+	}
+	return true, nil
+}
+
+// profileV3 converts the LoRaWAN settings into a v3 device profile, the region of v3 is set by the network server
+// and the RX settings of ABP devices aren't part of the v3 device profile
+func profileV3(name string, params LoraProfileParams) *api.DeviceProfile {
+	profile := &api.DeviceProfile{Name: name}
+	applyProfileV3(profile, params)
+	return profile
+}
+
+// applyProfileV3 sets the codec and LoRaWAN settings of a v3 device profile
+func applyProfileV3(profile *api.DeviceProfile, params LoraProfileParams) {
+	profile.RfRegion = params.Region
+	profile.MacVersion = params.MacVersion
+	profile.RegParamsRevision = params.RegParamsRevision
+	profile.MaxEirp = params.MaxEirp
+	profile.PayloadCodec = "CUSTOM_JS"
+	profile.PayloadDecoderScript = params.Codec
+	profile.PayloadEncoderScript = params.Codec
+	profile.UplinkInterval = durationpb.New(params.UplinkInterval)
+	profile.AdrAlgorithmId = params.AdrAlgorithmId
+	profile.SupportsJoin = params.SupportsOtaa
+	profile.SupportsClassB = params.SupportsClassB
+	profile.SupportsClassC = params.SupportsClassC
+}
+
+func (c *ChirpStackV3) DeleteProfile(ctx context.Context, name string) (err error) {
+	err = v3.DeleteProfile(c.conn, ctx, c.OrganizationId, c.ApplicationId, name)
 	return
 }
 
-func (c *ChirpStack) CreateProfile(ctx context.Context, name string, codec string) (id string, err error) {
-	id, err = v3.CreateProfile(c.conn, ctx, c.NetWorkServerId, c.OrganizationId, c.ApplicationId, name, codec)
+func (c *ChirpStackV3) FindProfile(ctx context.Context, name string) (id string, err error) {
+	id, err = v3.FindProfile(c.conn, ctx, c.OrganizationId, c.ApplicationId, name)
 	return
 }
 
-func (c *ChirpStack) DeleteProfile(ctx context.Context, name string) (err error) {
-	err = v3.DeleteProfile(c.conn, ctx, c.OrganizationId, c.ApplicationId, name)
+func (c *ChirpStackV3) ProfileInUse(ctx context.Context, profileId string) (used bool, err error) {
+	used, err = v3.ProfileInUse(c.conn, ctx, c.OrganizationId, profileId)
 	return
 }
 
-func (c *ChirpStack) CreateGateway(ctx context.Context, gateWayId string, name string) (err error) {
-	err = v3.CreateGateway(c.conn, ctx, gateWayId, name, c.OrganizationId, c.NetWorkServerId)
+func (c *ChirpStackV3) CreateGateway(ctx context.Context, gateWayId string, name string) (err error) {
+	err = v3.CreateGateway(c.conn, ctx, gateWayId, name, c.NetWorkServerId, c.OrganizationId)
 	return
 }
 
-func (c *ChirpStack) UpdateGateway(ctx context.Context, gateWayId string, name string) (err error) {
+func (c *ChirpStackV3) UpdateGateway(ctx context.Context, gateWayId string, name string) (err error) {
 	err = v3.UpdateGateway(c.conn, ctx, gateWayId, name)
 	return
 }
 
-func (c *ChirpStack) DeleteGateway(ctx context.Context, gateWayId string) (err error) {
+func (c *ChirpStackV3) DeleteGateway(ctx context.Context, gateWayId string) (err error) {
 	err = v3.DeleteGateway(c.conn, ctx, gateWayId)
 	return
 }
 
-func (c *ChirpStack) CreateDevice(ctx context.Context, DevEUI string, name string, deviceProfileId string) (err error) {
-	err = v3.CreateDevice(c.conn, ctx, DevEUI, name, deviceProfileId, c.ApplicationId)
+func (c *ChirpStackV3) CreateDevice(ctx context.Context, DevEUI string, name string, deviceProfileId string, disabled bool) (err error) {
+	err = v3.CreateDevice(c.conn, ctx, DevEUI, name, deviceProfileId, disabled, c.ApplicationId)
 	return
 }
 
-func (c *ChirpStack) ActivateDevice(ctx context.Context, DevEUI string, key string) (err error) {
-	err = v3.ActivateDevice(c.conn, ctx, DevEUI, key)
+func (c *ChirpStackV3) ActivateDevice(ctx context.Context, DevEUI string, devAddr string, appSKey string, nwkSEncKey string, sNwkSIntKey string, fNwkSIntKey string) (err error) {
+	err = v3.ActivateDevice(c.conn, ctx, DevEUI, devAddr, appSKey, nwkSEncKey, sNwkSIntKey, fNwkSIntKey)
 	return
 }
 
-func (c *ChirpStack) UpdateDevice(ctx context.Context, DevEUI string, name string) (err error) {
-	err = v3.UpdateDevice(c.conn, ctx, DevEUI, name)
+func (c *ChirpStackV3) CreateKeys(ctx context.Context, DevEUI string, appKey string, nwkKey string) (err error) {
+	err = v3.CreateKeys(c.conn, ctx, DevEUI, appKey, nwkKey)
 	return
 }
 
-func (c *ChirpStack) DeleteDevice(ctx context.Context, deviceName string, DevEUI string) (err error) {
+func (c *ChirpStackV3) UpdateDevice(ctx context.Context, DevEUI string, name string, deviceProfileId string, disabled bool) (err error) {
+	err = v3.UpdateDevice(c.conn, ctx, DevEUI, name, deviceProfileId, disabled)
+	return
+}
+
+func (c *ChirpStackV3) HasKeys(ctx context.Context, DevEUI string) (exists bool, err error) {
+	exists, err = v3.HasKeys(c.conn, ctx, DevEUI)
+	return
+}
+
+func (c *ChirpStackV3) HasActivation(ctx context.Context, DevEUI string) (activated bool, err error) {
+	activated, err = v3.HasActivation(c.conn, ctx, DevEUI)
+	return
+}
+
+func (c *ChirpStackV3) DeleteDevice(ctx context.Context, deviceName string, DevEUI string) (err error) {
 	err = v3.DeleteDevice(c.conn, ctx, deviceName, DevEUI)
 	return
 }
+
+func (c *ChirpStackV3) DeviceExists(ctx context.Context, DevEUI string) (exists bool, err error) {
+	exists, err = v3.DeviceExists(c.conn, ctx, DevEUI)
+	return
+}
+
+func (c *ChirpStackV3) ListDevices(ctx context.Context) (devices []DeviceInfo, err error) {
+	var items []*api.DeviceListItem
+	if items, err = v3.ListDevices(c.conn, ctx, c.ApplicationId); err != nil {
+		return
+	}
+
+	// 每个profile只查询一次入网方式
+	supportsJoin := make(map[string]bool)
+	devices = make([]DeviceInfo, 0, len(items))
+	for _, item := range items {
+		join, ok := supportsJoin[item.DeviceProfileId]
+		if !ok {
+			var profile *api.DeviceProfile
+			if profile, err = v3.GetProfile(c.conn, ctx, item.DeviceProfileId); err != nil {
+				return nil, err
+			}
+			join = profile.SupportsJoin
+			supportsJoin[item.DeviceProfileId] = join
+		}
+		devices = append(devices, DeviceInfo{
+			DevEUI:       item.DevEui,
+			Name:         item.Name,
+			Description:  item.Description,
+			ProfileName:  item.DeviceProfileName,
+			SupportsJoin: join,
+		})
+	}
+	return
+}
+
+func (c *ChirpStackV3) ListGateways(ctx context.Context) (gateways []GatewayInfo, err error) {
+	var items []*api.GatewayListItem
+	if items, err = v3.ListGateways(c.conn, ctx, c.OrganizationId); err != nil {
+		return
+	}
+	gateways = make([]GatewayInfo, 0, len(items))
+	for _, item := range items {
+		gateways = append(gateways, GatewayInfo{
+			GatewayId:   item.Id,
+			Name:        item.Name,
+			Description: item.Description,
+		})
+	}
+	return
+}
+
+func (c *ChirpStackV3) Enqueue(ctx context.Context, DevEUI string, fPort uint32, confirmed bool, data []byte, object string) (id string, err error) {
+	var fCnt uint32
+	if fCnt, err = v3.Enqueue(c.conn, ctx, DevEUI, fPort, confirmed, data, object); err != nil {
+		return
+	}
+	id = strconv.FormatUint(uint64(fCnt), 10)
+	return
+}
+
+func (c *ChirpStackV3) ListQueue(ctx context.Context, DevEUI string) (items []QueueItem, err error) {
+	var resp []*api.DeviceQueueItem
+	if resp, err = v3.ListQueue(c.conn, ctx, DevEUI); err != nil {
+		return
+	}
+	items = make([]QueueItem, 0, len(resp))
+	for _, item := range resp {
+		items = append(items, QueueItem{
+			Id:        strconv.FormatUint(uint64(item.FCnt), 10),
+			FCnt:      item.FCnt,
+			FPort:     item.FPort,
+			Confirmed: item.Confirmed,
+			Data:      item.Data,
+			Object:    item.JsonObject,
+		})
+	}
+	return
+}
+
+func (c *ChirpStackV3) CountQueue(ctx context.Context, DevEUI string) (count uint32, err error) {
+	count, err = v3.CountQueue(c.conn, ctx, DevEUI)
+	return
+}
+
+func (c *ChirpStackV3) FlushQueue(ctx context.Context, DevEUI string) (err error) {
+	err = v3.FlushQueue(c.conn, ctx, DevEUI)
+	return
+}
@@ -1,76 +1,270 @@
-//go:build chirpstack4
-// +build chirpstack4
-
 package driver
 
 import (
 	"context"
+	"fmt"
+	"strings"
 
-	"github.com/edgexfoundry/device-lora-go/config"
+	"github.com/chirpstack/chirpstack/api/go/v4/api"
+	"github.com/chirpstack/chirpstack/api/go/v4/common"
 	v4 "github.com/edgexfoundry/device-lora-go/utils/v4"
-	"google.golang.org/grpc"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
 )
 
-type ChirpStack struct {
-	conn          *grpc.ClientConn
-	config        config.ChirpStackConfig
+type ChirpStackV4 struct {
+	chirpStackClient
 	TenantId      string
 	ApplicationId string
 }
 
-func (c *ChirpStack) Init() (err error) {
-	c.conn, err = grpc.Dial(c.config.Host, grpc.WithInsecure())
+func (c *ChirpStackV4) Init() (err error) {
+	if err = c.dial(func(username string, password string) (string, error) {
+		return v4.Login(c.conn, username, password)
+	}); err != nil {
+		return
+	}
 
-	c.TenantId, c.ApplicationId, err = v4.Init(c.conn, c.config)
+	var ctx context.Context
+	if ctx, err = c.Login(); err != nil {
+		return
+	}
+	c.TenantId, c.ApplicationId, err = v4.Init(c.conn, ctx, v4.Scope{
+		TenantId:        c.config.TenantId,
+		TenantName:      c.config.TenantName,
+		ApplicationId:   c.config.ApplicationId,
+		ApplicationName: c.config.ApplicationName,
+		AutoCreate:      c.config.AutoCreate,
+	})
 	return
 }
 
-func (c *ChirpStack) Login() (ctx context.Context, err error) {
-	ctx, err = v4.Login(c.conn, c.config.Username, c.config.Password)
+func (c *ChirpStackV4) CreateProfile(ctx context.Context, name string, params LoraProfileParams) (id string, err error) {
+	var profile *api.DeviceProfile
+	if profile, err = profileV4(name, params); err != nil {
+		return
+	}
+	id, err = v4.CreateProfile(c.conn, ctx, c.TenantId, profile)
 	return
 }
 
-func (c *ChirpStack) CreateProfile(ctx context.Context, name string, codec string) (id string, err error) {
-	id, err = v4.CreateProfile(c.conn, ctx, c.TenantId, name, codec)
-	return
+// UpdateProfile updates the device profile of the name when its codec or LoRaWAN settings differ, the other settings
+// of the profile are kept
+func (c *ChirpStackV4) UpdateProfile(ctx context.Context, name string, params LoraProfileParams) (updated bool, err error) {
+	var id string
+	if id, err = v4.FindProfile(c.conn, ctx, c.TenantId, name); err != nil || len(id) == 0 {
+		return
+	}
+	var current *api.DeviceProfile
+	if current, err = v4.GetProfile(c.conn, ctx, id); err != nil {
+		return
+	}
+
+	profile := proto.Clone(current).(*api.DeviceProfile)
+	if err = applyProfileV4(profile, params); err != nil || proto.Equal(profile, current) {
+		return
+	}
+	if err = v4.UpdateProfile(c.conn, ctx, profile); err != nil {
+		return
+	}
+	return true, nil
+}
+
+// profileV4 converts the LoRaWAN settings into a v4 device profile
+func profileV4(name string, params LoraProfileParams) (*api.DeviceProfile, error) {
+	profile := &api.DeviceProfile{Name: name}
+	if err := applyProfileV4(profile, params); err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+// applyProfileV4 sets the codec and LoRaWAN settings of a v4 device profile
+func applyProfileV4(profile *api.DeviceProfile, params LoraProfileParams) error {
+	region, ok := common.Region_value[params.Region]
+	if !ok {
+		return fmt.Errorf("region %s is not supported by ChirpStack v4", params.Region)
+	}
+	macVersion, ok := common.MacVersion_value["LORAWAN_"+strings.ReplaceAll(params.MacVersion, ".", "_")]
+	if !ok {
+		return fmt.Errorf("MAC version %s is not supported by ChirpStack v4", params.MacVersion)
+	}
+	revision, ok := common.RegParamsRevision_value[strings.NewReplacer("-", "_", ".", "_").Replace(params.RegParamsRevision)]
+	if !ok {
+		return fmt.Errorf("regional parameters revision %s is not supported by ChirpStack v4", params.RegParamsRevision)
+	}
+
+	profile.Region = common.Region(region)
+	profile.MacVersion = common.MacVersion(macVersion)
+	profile.RegParamsRevision = common.RegParamsRevision(revision)
+	profile.AdrAlgorithmId = params.AdrAlgorithmId
+	profile.UplinkInterval = uint32(params.UplinkInterval.Seconds())
+	profile.PayloadCodecScript = params.Codec
+	profile.PayloadCodecRuntime = api.CodecRuntime_JS
+	profile.SupportsOtaa = params.SupportsOtaa
+	profile.SupportsClassB = params.SupportsClassB
+	profile.SupportsClassC = params.SupportsClassC
+	profile.AbpRx1Delay = params.Rx1Delay
+	profile.AbpRx2Dr = params.Rx2DataRate
+	profile.AbpRx2Freq = params.Rx2Frequency
+	return nil
 }
 
-func (c *ChirpStack) DeleteProfile(ctx context.Context, name string) (err error) {
+func (c *ChirpStackV4) DeleteProfile(ctx context.Context, name string) (err error) {
 	err = v4.DeleteProfile(c.conn, ctx, c.TenantId, name)
 	return
 }
 
-func (c *ChirpStack) CreateGateway(ctx context.Context, gateWayId string, name string) (err error) {
+func (c *ChirpStackV4) FindProfile(ctx context.Context, name string) (id string, err error) {
+	id, err = v4.FindProfile(c.conn, ctx, c.TenantId, name)
+	return
+}
+
+func (c *ChirpStackV4) ProfileInUse(ctx context.Context, profileId string) (used bool, err error) {
+	used, err = v4.ProfileInUse(c.conn, ctx, c.TenantId, profileId)
+	return
+}
+
+func (c *ChirpStackV4) CreateGateway(ctx context.Context, gateWayId string, name string) (err error) {
 	err = v4.CreateGateway(c.conn, ctx, gateWayId, name, c.TenantId)
 	return
 }
 
-func (c *ChirpStack) UpdateGateway(ctx context.Context, gateWayId string, name string) (err error) {
+func (c *ChirpStackV4) UpdateGateway(ctx context.Context, gateWayId string, name string) (err error) {
 	err = v4.UpdateGateway(c.conn, ctx, gateWayId, name)
 	return
 }
 
-func (c *ChirpStack) DeleteGateway(ctx context.Context, gateWayId string) (err error) {
+func (c *ChirpStackV4) DeleteGateway(ctx context.Context, gateWayId string) (err error) {
 	err = v4.DeleteGateway(c.conn, ctx, gateWayId)
 	return
 }
 
-func (c *ChirpStack) CreateDevice(ctx context.Context, DevEUI string, name string, deviceProfileId string) (err error) {
-	err = v4.CreateDevice(c.conn, ctx, DevEUI, name, deviceProfileId, c.ApplicationId)
+func (c *ChirpStackV4) CreateDevice(ctx context.Context, DevEUI string, name string, deviceProfileId string, disabled bool) (err error) {
+	err = v4.CreateDevice(c.conn, ctx, DevEUI, name, deviceProfileId, disabled, c.ApplicationId)
+	return
+}
+
+func (c *ChirpStackV4) ActivateDevice(ctx context.Context, DevEUI string, devAddr string, appSKey string, nwkSEncKey string, sNwkSIntKey string, fNwkSIntKey string) (err error) {
+	err = v4.ActivateDevice(c.conn, ctx, DevEUI, devAddr, appSKey, nwkSEncKey, sNwkSIntKey, fNwkSIntKey)
+	return
+}
+
+func (c *ChirpStackV4) CreateKeys(ctx context.Context, DevEUI string, appKey string, nwkKey string) (err error) {
+	err = v4.CreateKeys(c.conn, ctx, DevEUI, appKey, nwkKey)
+	return
+}
+
+func (c *ChirpStackV4) UpdateDevice(ctx context.Context, DevEUI string, name string, deviceProfileId string, disabled bool) (err error) {
+	err = v4.UpdateDevice(c.conn, ctx, DevEUI, name, deviceProfileId, disabled)
 	return
 }
 
-func (c *ChirpStack) ActivateDevice(ctx context.Context, DevEUI string, key string) (err error) {
-	err = v4.ActivateDevice(c.conn, ctx, DevEUI, key)
+func (c *ChirpStackV4) HasKeys(ctx context.Context, DevEUI string) (exists bool, err error) {
+	exists, err = v4.HasKeys(c.conn, ctx, DevEUI)
 	return
 }
 
-func (c *ChirpStack) UpdateDevice(ctx context.Context, DevEUI string, name string) (err error) {
-	err = v4.UpdateDevice(c.conn, ctx, DevEUI, name)
+func (c *ChirpStackV4) HasActivation(ctx context.Context, DevEUI string) (activated bool, err error) {
+	activated, err = v4.HasActivation(c.conn, ctx, DevEUI)
 	return
 }
 
-func (c *ChirpStack) DeleteDevice(ctx context.Context, deviceName string, DevEUI string) (err error) {
+func (c *ChirpStackV4) DeleteDevice(ctx context.Context, deviceName string, DevEUI string) (err error) {
 	err = v4.DeleteDevice(c.conn, ctx, deviceName, DevEUI)
 	return
 }
+
+func (c *ChirpStackV4) DeviceExists(ctx context.Context, DevEUI string) (exists bool, err error) {
+	exists, err = v4.DeviceExists(c.conn, ctx, DevEUI)
+	return
+}
+
+func (c *ChirpStackV4) ListDevices(ctx context.Context) (devices []DeviceInfo, err error) {
+	var items []*api.DeviceListItem
+	if items, err = v4.ListDevices(c.conn, ctx, c.ApplicationId); err != nil {
+		return
+	}
+
+	// 每个profile只查询一次入网方式
+	supportsJoin := make(map[string]bool)
+	devices = make([]DeviceInfo, 0, len(items))
+	for _, item := range items {
+		join, ok := supportsJoin[item.DeviceProfileId]
+		if !ok {
+			var profile *api.DeviceProfile
+			if profile, err = v4.GetProfile(c.conn, ctx, item.DeviceProfileId); err != nil {
+				return nil, err
+			}
+			join = profile.SupportsOtaa
+			supportsJoin[item.DeviceProfileId] = join
+		}
+		devices = append(devices, DeviceInfo{
+			DevEUI:       item.DevEui,
+			Name:         item.Name,
+			Description:  item.Description,
+			ProfileName:  item.DeviceProfileName,
+			SupportsJoin: join,
+		})
+	}
+	return
+}
+
+func (c *ChirpStackV4) ListGateways(ctx context.Context) (gateways []GatewayInfo, err error) {
+	var items []*api.GatewayListItem
+	if items, err = v4.ListGateways(c.conn, ctx, c.TenantId); err != nil {
+		return
+	}
+	gateways = make([]GatewayInfo, 0, len(items))
+	for _, item := range items {
+		gateways = append(gateways, GatewayInfo{
+			GatewayId:   item.GatewayId,
+			Name:        item.Name,
+			Description: item.Description,
+		})
+	}
+	return
+}
+
+func (c *ChirpStackV4) Enqueue(ctx context.Context, DevEUI string, fPort uint32, confirmed bool, data []byte, object string) (id string, err error) {
+	id, err = v4.Enqueue(c.conn, ctx, DevEUI, fPort, confirmed, data, object)
+	return
+}
+
+func (c *ChirpStackV4) ListQueue(ctx context.Context, DevEUI string) (items []QueueItem, err error) {
+	var resp []*api.DeviceQueueItem
+	if resp, err = v4.ListQueue(c.conn, ctx, DevEUI); err != nil {
+		return
+	}
+	items = make([]QueueItem, 0, len(resp))
+	for _, item := range resp {
+		var object string
+		if item.Object != nil {
+			var buf []byte
+			if buf, err = protojson.Marshal(item.Object); err != nil {
+				return nil, err
+			}
+			object = string(buf)
+		}
+		items = append(items, QueueItem{
+			Id:        item.Id,
+			FCnt:      item.FCntDown,
+			FPort:     item.FPort,
+			Confirmed: item.Confirmed,
+			Data:      item.Data,
+			Object:    object,
+			IsPending: item.IsPending,
+		})
+	}
+	return
+}
+
+func (c *ChirpStackV4) CountQueue(ctx context.Context, DevEUI string) (count uint32, err error) {
+	count, err = v4.CountQueue(c.conn, ctx, DevEUI)
+	return
+}
+
+func (c *ChirpStackV4) FlushQueue(ctx context.Context, DevEUI string) (err error) {
+	err = v4.FlushQueue(c.conn, ctx, DevEUI)
+	return
+}
@@ -6,6 +6,113 @@ const (
 	LoraEUI      = "eui"
 	LoraGateway  = "gateway"
 
+	// Lora protocol param of discovered devices which carries the EdgeX device profile for provision watchers
+	LoraProfileName = "profileName"
+
+	// Lora device activation protocol params
+	LoraActivation = "activation"
+	LoraAppKey     = "appKey"
+	LoraNwkKey     = "nwkKey"
+
+	// Lora ABP session protocol params, LoRaWAN 1.1 devices use the split network session keys instead of nwkSKey
+	LoraDevAddr     = "devAddr"
+	LoraAppSKey     = "appSKey"
+	LoraNwkSKey     = "nwkSKey"
+	LoraNwkSEncKey  = "nwkSEncKey"
+	LoraSNwkSIntKey = "sNwkSIntKey"
+	LoraFNwkSIntKey = "fNwkSIntKey"
+
+	// ChirpStack secret keys
+	SecretUsername    = "username"
+	SecretPassword    = "password"
+	SecretApiToken    = "apiToken"
+	SecretActivateKey = "activateKey"
+
+	// ChirpStack TLS secret keys
+	SecretCACert     = "caCert"
+	SecretClientCert = "clientCert"
+	SecretClientKey  = "clientKey"
+
+	// Lora device activations
+	ActivationOTAA = "otaa"
+	ActivationABP  = "abp"
+
 	// Lora device profile optional params
-	CODEC = "codec"
+	CODEC     = "codec"
+	FPORT     = "fPort"
+	CONFIRMED = "confirmed"
+	ENCODING  = "encoding"
+	WIDTH     = "width"
+	JSONPATH  = "jsonPath"
+
+	// Lora codec resource optional params of the LoRaWAN settings of the ChirpStack device profile
+	REGION            = "region"
+	MACVERSION        = "macVersion"
+	REGPARAMSREVISION = "regParamsRevision"
+	UPLINKINTERVAL    = "uplinkInterval"
+	MAXEIRP           = "maxEirp"
+	SUPPORTSOTAA      = "supportsOtaa"
+	SUPPORTSCLASSB    = "supportsClassB"
+	SUPPORTSCLASSC    = "supportsClassC"
+	RX1DELAY          = "rx1Delay"
+	RX2DATARATE       = "rx2DataRate"
+	RX2FREQUENCY      = "rx2Frequency"
+	ADRALGORITHM      = "adrAlgorithmId"
+
+	// Lora codec resource optional param which publishes the uplink radio metadata as readings or reading tags
+	RADIOMETADATA         = "radioMetadata"
+	RadioMetadataReadings = "readings"
+	RadioMetadataTags     = "tags"
+
+	// Lora device profile resources which receive the uplink radio metadata
+	RssiResource            = "rssi"
+	SnrResource             = "snr"
+	GatewayIdResource       = "gatewayId"
+	FrequencyResource       = "frequency"
+	DataRateResource        = "dataRate"
+	SpreadingFactorResource = "spreadingFactor"
+	FCntResource            = "fCnt"
+	FPortResource           = "fPort"
+
+	// Lora downlink payload encodings
+	EncodingCodec        = "codec"
+	EncodingASCII        = "ascii"
+	EncodingHex          = "hex"
+	EncodingBase64       = "base64"
+	EncodingBigEndian    = "bigEndian"
+	EncodingLittleEndian = "littleEndian"
+
+	// Lora device profile resource which receives confirmed downlink status
+	DownlinkStatusResource = "downlinkStatus"
+
+	// Lora device profile resources which manage the device queue
+	QueueResource      = "queue"
+	QueueCountResource = "queueCount"
+	FlushQueueResource = "flushQueue"
+
+	// Reconciler orphan policies, orphans are ChirpStack devices and gateways which aren't EdgeX devices
+	OrphanPolicyReport = "report"
+	OrphanPolicyDelete = "delete"
+
+	// Reconciler metric of the number of differences found by the last reconciliation
+	ReconcileDriftMetric = "ReconcileDrift"
+
+	// ChirpStack device profile defaults when neither the service config nor the device profile sets them
+	DefaultRegion                   = "CN470"
+	DefaultMacVersion               = "1.0.2"
+	DefaultRegParamsRevision        = "A"
+	DefaultUplinkInterval           = "10m"
+	DefaultMaxEirp           uint32 = 20
+	DefaultAdrAlgorithm             = "default"
+
+	// Lora downlink defaults
+	DefaultFPort           uint32 = 1
+	DefaultDownlinkTimeout        = "30m"
+)
+
+var (
+	// LoRaWAN regions, MAC versions and regional parameters revisions of the ChirpStack device profiles
+	LoraRegions            = []string{"EU868", "US915", "CN779", "EU433", "AU915", "CN470", "AS923", "AS923_2", "AS923_3", "AS923_4", "KR920", "IN865", "RU864", "ISM2400"}
+	LoraMacVersions        = []string{"1.0.0", "1.0.1", "1.0.2", "1.0.3", "1.0.4", "1.1.0"}
+	LoraRegParamsRevisions = []string{"A", "B", "RP002-1.0.0", "RP002-1.0.1", "RP002-1.0.2", "RP002-1.0.3"}
 )
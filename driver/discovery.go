@@ -0,0 +1,115 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	sdkModels "github.com/edgexfoundry/device-sdk-go/v3/pkg/models"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/models"
+)
+
+// Discover reports the devices of the ChirpStack application and the gateways of the tenant which aren't EdgeX
+// devices yet, provision watchers add them by matching the lora protocol properties such as profileName
+func (driver *LoraDriver) Discover() (err error) {
+	// 登录chirpstack
+	var ctx context.Context
+	if ctx, err = driver.chirp.Login(); err != nil {
+		return
+	}
+
+	var devices []DeviceInfo
+	if devices, err = driver.chirp.ListDevices(ctx); err != nil {
+		return fmt.Errorf("List ChirpStack devices failed: %s", err.Error())
+	}
+	var gateways []GatewayInfo
+	if gateways, err = driver.chirp.ListGateways(ctx); err != nil {
+		return fmt.Errorf("List ChirpStack gateways failed: %s", err.Error())
+	}
+
+	// 已添加到EdgeX的设备不再上报
+	known := make(map[string]bool)
+	for _, device := range driver.sdk.Devices() {
+		if protocolParams, err := getDeviceParameters(device.Protocols); err == nil {
+			known[strings.ToLower(protocolParams.EUI)] = true
+		}
+	}
+
+	discovered := discoveredDevices(devices, gateways, driver.profileMapping, known)
+	for _, device := range discovered {
+		properties := device.Protocols[LoraProtocol]
+		if gateway, _ := properties[LoraGateway].(bool); gateway {
+			continue
+		}
+		if _, err := driver.sdk.GetProfileByName(fmt.Sprintf("%v", properties[LoraProfileName])); err != nil {
+			driver.logger.Warnf("Discovered device %s uses profile %v which isn't an EdgeX device profile", device.Name, properties[LoraProfileName])
+		}
+	}
+
+	driver.logger.Infof("Discovered %d ChirpStack devices and gateways", len(discovered))
+	driver.sdk.DiscoveredDeviceChannel() <- discovered
+	return nil
+}
+
+// discoveredDevices converts the ChirpStack devices and gateways which aren't known by their EUI into discovered
+// devices, the ChirpStack device profile is replaced by its EdgeX device profile in the mapping
+func discoveredDevices(devices []DeviceInfo, gateways []GatewayInfo, mapping map[string]string, known map[string]bool) []sdkModels.DiscoveredDevice {
+	discovered := make([]sdkModels.DiscoveredDevice, 0, len(devices)+len(gateways))
+
+	for _, device := range devices {
+		if known[strings.ToLower(device.DevEUI)] {
+			continue
+		}
+
+		profileName := device.ProfileName
+		if name, ok := mapping[profileName]; ok {
+			profileName = name
+		}
+		activation := ActivationABP
+		if device.SupportsJoin {
+			activation = ActivationOTAA
+		}
+
+		discovered = append(discovered, sdkModels.DiscoveredDevice{
+			Name:        discoveredName(device.Name, device.DevEUI),
+			Description: device.Description,
+			Labels:      []string{LoraProtocol},
+			Protocols: map[string]models.ProtocolProperties{
+				LoraProtocol: {
+					LoraEUI:         device.DevEUI,
+					LoraGateway:     false,
+					LoraActivation:  activation,
+					LoraProfileName: profileName,
+				},
+			},
+		})
+	}
+
+	for _, gateway := range gateways {
+		if known[strings.ToLower(gateway.GatewayId)] {
+			continue
+		}
+
+		discovered = append(discovered, sdkModels.DiscoveredDevice{
+			Name:        discoveredName(gateway.Name, gateway.GatewayId),
+			Description: gateway.Description,
+			Labels:      []string{LoraProtocol, LoraGateway},
+			Protocols: map[string]models.ProtocolProperties{
+				LoraProtocol: {
+					LoraEUI:     gateway.GatewayId,
+					LoraGateway: true,
+				},
+			},
+		})
+	}
+
+	return discovered
+}
+
+// discoveredName returns the ChirpStack name of a device, or its EUI when it has no name
+func discoveredName(name string, eui string) string {
+	if len(name) == 0 {
+		return eui
+	}
+	return name
+}
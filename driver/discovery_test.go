@@ -0,0 +1,48 @@
+package driver
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/models"
+)
+
+func TestDiscoveredDevices(t *testing.T) {
+	devices := []DeviceInfo{
+		{DevEUI: "9d13b5893728d5f6", Name: "sensor-1", ProfileName: "cc10ld", SupportsJoin: true},
+		{DevEUI: "9d13b5893728d5f7", Name: "sensor-2", ProfileName: "Lora-Device-CC10LD"},
+		{DevEUI: "9D13B5893728D5F8", Name: "known"},
+		{DevEUI: "9d13b5893728d5f9"},
+	}
+	gateways := []GatewayInfo{
+		{GatewayId: "12c94daec6a7984d", Name: "gateway-1", Description: "roof"},
+		{GatewayId: "12c94daec6a7984e", Name: "known-gateway"},
+	}
+	mapping := map[string]string{"cc10ld": "Lora-Device-CC10LD"}
+	known := map[string]bool{"9d13b5893728d5f8": true, "12c94daec6a7984e": true}
+
+	discovered := discoveredDevices(devices, gateways, mapping, known)
+
+	expected := []struct {
+		name      string
+		protocols models.ProtocolProperties
+	}{
+		{"sensor-1", models.ProtocolProperties{LoraEUI: "9d13b5893728d5f6", LoraGateway: false, LoraActivation: ActivationOTAA, LoraProfileName: "Lora-Device-CC10LD"}},
+		{"sensor-2", models.ProtocolProperties{LoraEUI: "9d13b5893728d5f7", LoraGateway: false, LoraActivation: ActivationABP, LoraProfileName: "Lora-Device-CC10LD"}},
+		{"9d13b5893728d5f9", models.ProtocolProperties{LoraEUI: "9d13b5893728d5f9", LoraGateway: false, LoraActivation: ActivationABP, LoraProfileName: ""}},
+		{"gateway-1", models.ProtocolProperties{LoraEUI: "12c94daec6a7984d", LoraGateway: true}},
+	}
+	if len(discovered) != len(expected) {
+		t.Fatalf("expected %d discovered devices, got %d: %+v", len(expected), len(discovered), discovered)
+	}
+	for i, tt := range expected {
+		t.Run(tt.name, func(t *testing.T) {
+			if discovered[i].Name != tt.name {
+				t.Errorf("expected name %s, got %s", tt.name, discovered[i].Name)
+			}
+			if !reflect.DeepEqual(discovered[i].Protocols[LoraProtocol], tt.protocols) {
+				t.Errorf("expected protocols %v, got %v", tt.protocols, discovered[i].Protocols[LoraProtocol])
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package driver
+
+import (
+	"sync"
+	"time"
+
+	sdkModels "github.com/edgexfoundry/device-sdk-go/v3/pkg/models"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/common"
+)
+
+// Confirmed downlink status
+const (
+	DownlinkSent    = "sent"
+	DownlinkAck     = "ack"
+	DownlinkNack    = "nack"
+	DownlinkTimeout = "timeout"
+)
+
+// QueueItem is a downlink waiting in the chirpstack device queue
+type QueueItem struct {
+	Id        string `json:"id"`
+	FCnt      uint32 `json:"fCnt"`
+	FPort     uint32 `json:"fPort"`
+	Confirmed bool   `json:"confirmed"`
+	Data      []byte `json:"data,omitempty"`
+	Object    string `json:"object,omitempty"`
+	IsPending bool   `json:"isPending"`
+}
+
+type pendingDownlink struct {
+	deviceName   string
+	resourceName string
+	id           string
+	timer        *time.Timer
+}
+
+// DownlinkTracker tracks enqueued confirmed downlinks until the device acknowledges them or the timeout expires,
+// the downlinks are keyed by device name and the chirpstack queue id (v4) or fCnt (v3)
+type DownlinkTracker struct {
+	driver  *LoraDriver
+	timeout time.Duration
+	mutex   sync.Mutex
+	pending map[string]*pendingDownlink
+}
+
+func NewDownlinkTracker(driver *LoraDriver, timeout time.Duration) *DownlinkTracker {
+	return &DownlinkTracker{
+		driver:  driver,
+		timeout: timeout,
+		pending: make(map[string]*pendingDownlink),
+	}
+}
+
+func downlinkKey(deviceName string, id string) string {
+	return deviceName + "/" + id
+}
+
+// Track starts tracking a confirmed downlink
+func (t *DownlinkTracker) Track(deviceName string, id string, resourceName string) {
+	key := downlinkKey(deviceName, id)
+	downlink := &pendingDownlink{
+		deviceName:   deviceName,
+		resourceName: resourceName,
+		id:           id,
+	}
+	downlink.timer = time.AfterFunc(t.timeout, func() {
+		t.Resolve(deviceName, id, DownlinkTimeout)
+	})
+
+	t.mutex.Lock()
+	if old, ok := t.pending[key]; ok {
+		old.timer.Stop()
+	}
+	t.pending[key] = downlink
+	t.mutex.Unlock()
+}
+
+// Sent reports that a tracked downlink has been transmitted by a gateway, it stays pending until ack or timeout
+func (t *DownlinkTracker) Sent(deviceName string, id string) {
+	t.mutex.Lock()
+	downlink, ok := t.pending[downlinkKey(deviceName, id)]
+	t.mutex.Unlock()
+
+	if ok {
+		t.driver.pushDownlinkStatus(downlink.deviceName, downlink.id, downlink.resourceName, DownlinkSent)
+	}
+}
+
+// Resolve stops tracking a downlink and reports its final status
+func (t *DownlinkTracker) Resolve(deviceName string, id string, status string) {
+	key := downlinkKey(deviceName, id)
+
+	t.mutex.Lock()
+	downlink, ok := t.pending[key]
+	if ok {
+		downlink.timer.Stop()
+		delete(t.pending, key)
+	}
+	t.mutex.Unlock()
+
+	if ok {
+		t.driver.pushDownlinkStatus(downlink.deviceName, downlink.id, downlink.resourceName, status)
+	}
+}
+
+// pushDownlinkStatus sends the downlink status as a reading of the downlinkStatus resource
+func (driver *LoraDriver) pushDownlinkStatus(deviceName string, id string, resourceName string, status string) {
+	driver.logger.Infof("Downlink %s of device %s resource %s: %s", id, deviceName, resourceName, status)
+
+	deviceResource, ok := driver.sdk.DeviceResource(deviceName, DownlinkStatusResource)
+	if !ok {
+		driver.logger.Debugf("Device %s has no %s resource, downlink status ignored", deviceName, DownlinkStatusResource)
+		return
+	}
+
+	var val interface{}
+	switch deviceResource.Properties.ValueType {
+	case common.ValueTypeObject:
+		val = map[string]interface{}{
+			"id":       id,
+			"resource": resourceName,
+			"status":   status,
+		}
+	case common.ValueTypeString:
+		val = status
+	default:
+		driver.logger.Errorf("Resource %s must be Object or String type", DownlinkStatusResource)
+		return
+	}
+
+	commandValue, err := sdkModels.NewCommandValue(deviceResource.Name, deviceResource.Properties.ValueType, val)
+	if err != nil {
+		driver.logger.Errorf("Downlink status ignored: %v", err)
+		return
+	}
+	commandValue.Origin = time.Now().UnixNano()
+
+	driver.AsyncCh <- &sdkModels.AsyncValues{
+		DeviceName:    deviceName,
+		SourceName:    deviceResource.Name,
+		CommandValues: []*sdkModels.CommandValue{commandValue},
+	}
+}
+
+// ackStatus converts the acknowledged flag of an ack event into a downlink status
+func ackStatus(acknowledged bool) string {
+	if acknowledged {
+		return DownlinkAck
+	}
+	return DownlinkNack
+}
@@ -0,0 +1,203 @@
+package driver
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+
+	sdkModels "github.com/edgexfoundry/device-sdk-go/v3/pkg/models"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/common"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/models"
+	"github.com/spf13/cast"
+)
+
+// encodeDownlink converts the written command value into a downlink payload, the payload is either raw
+// bytes (data) or a JSON object which is encoded by the chirpstack codec
+func encodeDownlink(resource models.DeviceResource, value *sdkModels.CommandValue) (data []byte, object string, err error) {
+	valueType := resource.Properties.ValueType
+
+	var encoding string
+	if encoding, err = getEncoding(resource); err != nil {
+		return
+	}
+
+	switch valueType {
+	case common.ValueTypeObject:
+		if encoding != EncodingCodec {
+			return nil, "", fmt.Errorf("encoding %s not supported by %s type", encoding, valueType)
+		}
+		object, err = encodeObject(value.Value)
+	case common.ValueTypeString:
+		var str string
+		if str, err = value.StringValue(); err != nil {
+			return
+		}
+		data, err = encodeString(str, encoding)
+	case common.ValueTypeBinary:
+		data, err = value.BinaryValue()
+	case common.ValueTypeBool:
+		var b bool
+		if b, err = value.BoolValue(); err != nil {
+			return
+		}
+		data = []byte{0}
+		if b {
+			data[0] = 1
+		}
+	case common.ValueTypeUint8, common.ValueTypeUint16, common.ValueTypeUint32, common.ValueTypeUint64,
+		common.ValueTypeInt8, common.ValueTypeInt16, common.ValueTypeInt32, common.ValueTypeInt64,
+		common.ValueTypeFloat32, common.ValueTypeFloat64:
+		var width int
+		if width, err = getWidth(resource); err != nil {
+			return
+		}
+		data, err = encodeNumber(value.Value, valueType, encoding, width)
+	default:
+		return nil, "", fmt.Errorf("Unsupported value type: %v", valueType)
+	}
+
+	return
+}
+
+// getEncoding reads the encoding optional attribute, falling back to the default encoding of the value type
+func getEncoding(resource models.DeviceResource) (string, error) {
+	valueType := resource.Properties.ValueType
+	value, ok := resource.Properties.Optional[ENCODING]
+	if !ok {
+		switch valueType {
+		case common.ValueTypeObject:
+			return EncodingCodec, nil
+		case common.ValueTypeString:
+			return EncodingASCII, nil
+		default:
+			return EncodingBigEndian, nil
+		}
+	}
+
+	encoding := fmt.Sprintf("%v", value)
+	switch encoding {
+	case EncodingCodec, EncodingASCII, EncodingHex, EncodingBase64, EncodingBigEndian, EncodingLittleEndian:
+		return encoding, nil
+	}
+	return "", fmt.Errorf("unknown encoding %s", encoding)
+}
+
+// getWidth reads the width optional attribute, 0 means the natural width of the value type
+func getWidth(resource models.DeviceResource) (int, error) {
+	value, ok := resource.Properties.Optional[WIDTH]
+	if !ok {
+		return 0, nil
+	}
+
+	width, err := cast.ToIntE(value)
+	if err != nil {
+		return 0, fmt.Errorf("width is not a number: %v", value)
+	}
+	switch width {
+	case 1, 2, 4, 8:
+		return width, nil
+	}
+	return 0, fmt.Errorf("width %d must be 1, 2, 4 or 8", width)
+}
+
+func encodeObject(value interface{}) (string, error) {
+	buf, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	if !json.Valid(buf) {
+		return "", fmt.Errorf("PUT request data is invalid JSON string")
+	}
+	return string(buf), nil
+}
+
+func encodeString(str string, encoding string) ([]byte, error) {
+	switch encoding {
+	case EncodingASCII:
+		return []byte(str), nil
+	case EncodingHex:
+		// 允许0x前缀和空格分隔, 如: 0x01 03 01 f4
+		str = strings.TrimPrefix(strings.TrimPrefix(str, "0x"), "0X")
+		return hex.DecodeString(strings.ReplaceAll(str, " ", ""))
+	case EncodingBase64:
+		return base64.StdEncoding.DecodeString(str)
+	}
+	return nil, fmt.Errorf("encoding %s not supported by %s type", encoding, common.ValueTypeString)
+}
+
+func encodeNumber(value interface{}, valueType string, encoding string, width int) ([]byte, error) {
+	if encoding != EncodingBigEndian && encoding != EncodingLittleEndian {
+		return nil, fmt.Errorf("encoding %s not supported by %s type", encoding, valueType)
+	}
+
+	var bits uint64
+	switch valueType {
+	case common.ValueTypeFloat32, common.ValueTypeFloat64:
+		f, err := cast.ToFloat64E(value)
+		if err != nil {
+			return nil, err
+		}
+		if width == 0 {
+			width = naturalWidth(valueType)
+		}
+		switch width {
+		case 4:
+			bits = uint64(math.Float32bits(float32(f)))
+		case 8:
+			bits = math.Float64bits(f)
+		default:
+			return nil, fmt.Errorf("width %d not supported by %s type", width, valueType)
+		}
+	case common.ValueTypeInt8, common.ValueTypeInt16, common.ValueTypeInt32, common.ValueTypeInt64:
+		i, err := cast.ToInt64E(value)
+		if err != nil {
+			return nil, err
+		}
+		if width == 0 {
+			width = naturalWidth(valueType)
+		}
+		if width < 8 {
+			limit := int64(1) << (width*8 - 1)
+			if i < -limit || i >= limit {
+				return nil, fmt.Errorf("value %d does not fit in %d bytes", i, width)
+			}
+		}
+		bits = uint64(i)
+	default:
+		u, err := cast.ToUint64E(value)
+		if err != nil {
+			return nil, err
+		}
+		if width == 0 {
+			width = naturalWidth(valueType)
+		}
+		if width < 8 && u >= uint64(1)<<(width*8) {
+			return nil, fmt.Errorf("value %d does not fit in %d bytes", u, width)
+		}
+		bits = u
+	}
+
+	buf := make([]byte, 8)
+	if encoding == EncodingLittleEndian {
+		binary.LittleEndian.PutUint64(buf, bits)
+		return buf[:width], nil
+	}
+	binary.BigEndian.PutUint64(buf, bits)
+	return buf[8-width:], nil
+}
+
+func naturalWidth(valueType string) int {
+	switch valueType {
+	case common.ValueTypeUint8, common.ValueTypeInt8:
+		return 1
+	case common.ValueTypeUint16, common.ValueTypeInt16:
+		return 2
+	case common.ValueTypeUint32, common.ValueTypeInt32, common.ValueTypeFloat32:
+		return 4
+	}
+	return 8
+}
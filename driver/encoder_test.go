@@ -0,0 +1,177 @@
+package driver
+
+import (
+	"bytes"
+	"testing"
+
+	sdkModels "github.com/edgexfoundry/device-sdk-go/v3/pkg/models"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/common"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/models"
+)
+
+func newResource(valueType string, optional map[string]any) models.DeviceResource {
+	return models.DeviceResource{
+		Name: "command",
+		Properties: models.ResourceProperties{
+			ValueType: valueType,
+			Optional:  optional,
+		},
+	}
+}
+
+func newCommandValue(t *testing.T, valueType string, value interface{}) *sdkModels.CommandValue {
+	cv, err := sdkModels.NewCommandValue("command", valueType, value)
+	if err != nil {
+		t.Fatalf("create command value failed: %v", err)
+	}
+	return cv
+}
+
+func TestEncodeObject(t *testing.T) {
+	tests := []struct {
+		name     string
+		optional map[string]any
+		value    map[string]interface{}
+		expected string
+		err      bool
+	}{
+		{"default codec", nil, map[string]interface{}{"led": true}, `{"led":true}`, false},
+		{"explicit codec", map[string]any{ENCODING: EncodingCodec}, map[string]interface{}{"n": 1}, `{"n":1}`, false},
+		{"hex not allowed", map[string]any{ENCODING: EncodingHex}, map[string]interface{}{"n": 1}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resource := newResource(common.ValueTypeObject, tt.optional)
+			data, object, err := encodeDownlink(resource, newCommandValue(t, common.ValueTypeObject, tt.value))
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected error, got object %s", object)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if data != nil {
+				t.Errorf("expected no raw data, got %x", data)
+			}
+			if object != tt.expected {
+				t.Errorf("expected object %s, got %s", tt.expected, object)
+			}
+		})
+	}
+}
+
+func TestEncodeString(t *testing.T) {
+	tests := []struct {
+		name     string
+		optional map[string]any
+		value    string
+		expected []byte
+		err      bool
+	}{
+		{"default ascii", nil, "at+pptm=add,1,poll,0000000", []byte("at+pptm=add,1,poll,0000000"), false},
+		{"ascii", map[string]any{ENCODING: EncodingASCII}, "at+pptm=?", []byte("at+pptm=?"), false},
+		{"hex", map[string]any{ENCODING: EncodingHex}, "010301f4", []byte{0x01, 0x03, 0x01, 0xf4}, false},
+		{"hex with prefix and spaces", map[string]any{ENCODING: EncodingHex}, "0x01 03 01 F4", []byte{0x01, 0x03, 0x01, 0xf4}, false},
+		{"invalid hex", map[string]any{ENCODING: EncodingHex}, "0g", nil, true},
+		{"base64", map[string]any{ENCODING: EncodingBase64}, "YXQrcHB0bT1hZGQsMSxwb2xsLDAwMDAwMDA=", []byte("at+pptm=add,1,poll,0000000"), false},
+		{"invalid base64", map[string]any{ENCODING: EncodingBase64}, "!!", nil, true},
+		{"unknown encoding", map[string]any{ENCODING: "utf16"}, "at", nil, true},
+		{"endian not allowed", map[string]any{ENCODING: EncodingBigEndian}, "at", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resource := newResource(common.ValueTypeString, tt.optional)
+			data, _, err := encodeDownlink(resource, newCommandValue(t, common.ValueTypeString, tt.value))
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected error, got data %x", data)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !bytes.Equal(data, tt.expected) {
+				t.Errorf("expected %x, got %x", tt.expected, data)
+			}
+		})
+	}
+}
+
+func TestEncodeNumber(t *testing.T) {
+	tests := []struct {
+		name      string
+		valueType string
+		optional  map[string]any
+		value     interface{}
+		expected  []byte
+		err       bool
+	}{
+		{"uint8", common.ValueTypeUint8, nil, uint8(0x7f), []byte{0x7f}, false},
+		{"uint16 big endian", common.ValueTypeUint16, nil, uint16(0x0102), []byte{0x01, 0x02}, false},
+		{"uint16 little endian", common.ValueTypeUint16, map[string]any{ENCODING: EncodingLittleEndian}, uint16(0x0102), []byte{0x02, 0x01}, false},
+		{"uint32 width 2", common.ValueTypeUint32, map[string]any{WIDTH: 2}, uint32(0x0304), []byte{0x03, 0x04}, false},
+		{"uint32 width 2 overflow", common.ValueTypeUint32, map[string]any{WIDTH: 2}, uint32(0x10000), nil, true},
+		{"uint64", common.ValueTypeUint64, nil, uint64(1), []byte{0, 0, 0, 0, 0, 0, 0, 1}, false},
+		{"int8 negative", common.ValueTypeInt8, nil, int8(-1), []byte{0xff}, false},
+		{"int16 width 4 little endian", common.ValueTypeInt16, map[string]any{ENCODING: EncodingLittleEndian, WIDTH: "4"}, int16(-2), []byte{0xfe, 0xff, 0xff, 0xff}, false},
+		{"int32 width 1 overflow", common.ValueTypeInt32, map[string]any{WIDTH: 1}, int32(128), nil, true},
+		{"int64", common.ValueTypeInt64, nil, int64(-1), []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, false},
+		{"float32", common.ValueTypeFloat32, nil, float32(1), []byte{0x3f, 0x80, 0x00, 0x00}, false},
+		{"float32 little endian", common.ValueTypeFloat32, map[string]any{ENCODING: EncodingLittleEndian}, float32(1), []byte{0x00, 0x00, 0x80, 0x3f}, false},
+		{"float64", common.ValueTypeFloat64, nil, float64(1), []byte{0x3f, 0xf0, 0, 0, 0, 0, 0, 0}, false},
+		{"float64 width 4", common.ValueTypeFloat64, map[string]any{WIDTH: 4}, float64(1), []byte{0x3f, 0x80, 0x00, 0x00}, false},
+		{"float32 width 2", common.ValueTypeFloat32, map[string]any{WIDTH: 2}, float32(1), nil, true},
+		{"invalid width", common.ValueTypeUint16, map[string]any{WIDTH: 3}, uint16(1), nil, true},
+		{"ascii not allowed", common.ValueTypeUint16, map[string]any{ENCODING: EncodingASCII}, uint16(1), nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resource := newResource(tt.valueType, tt.optional)
+			data, _, err := encodeDownlink(resource, newCommandValue(t, tt.valueType, tt.value))
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected error, got data %x", data)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !bytes.Equal(data, tt.expected) {
+				t.Errorf("expected %x, got %x", tt.expected, data)
+			}
+		})
+	}
+}
+
+func TestEncodeBoolAndBinary(t *testing.T) {
+	tests := []struct {
+		name      string
+		valueType string
+		value     interface{}
+		expected  []byte
+	}{
+		{"bool true", common.ValueTypeBool, true, []byte{1}},
+		{"bool false", common.ValueTypeBool, false, []byte{0}},
+		{"binary", common.ValueTypeBinary, []byte{0xde, 0xad}, []byte{0xde, 0xad}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resource := newResource(tt.valueType, nil)
+			data, _, err := encodeDownlink(resource, newCommandValue(t, tt.valueType, tt.value))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !bytes.Equal(data, tt.expected) {
+				t.Errorf("expected %x, got %x", tt.expected, data)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package driver
+
+import (
+	"testing"
+	"time"
+
+	v3api "github.com/brocaar/chirpstack-api/go/v3/as/external/api"
+	v4api "github.com/chirpstack/chirpstack/api/go/v4/api"
+)
+
+func TestDecodeEvents(t *testing.T) {
+	received := time.Date(2024, 5, 1, 8, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		event    *DeviceEvent
+		expected DeviceEvent
+		err      bool
+	}{
+		{"v3 uplink", decodeEventV3(&v3api.StreamDeviceEventLogsResponse{Type: "up", PayloadJson: `{
+			"objectJSON":"{\"temperature\":21.5}","publishedAt":"2024-05-01T08:30:00Z","fCnt":7,"fPort":2,
+			"rxInfo":[{"gatewayID":"a","rssi":-110,"loRaSNR":-3},{"gatewayID":"b","rssi":-90,"loRaSNR":7.5}],
+			"txInfo":{"frequency":486300000,"dr":2}}`}),
+			DeviceEvent{Type: EventUp, Time: received, Metadata: &RadioMetadata{GatewayId: "b", Rssi: -90, Snr: 7.5, Frequency: 486300000, DataRate: 2, FCnt: 7, FPort: 2}}, false},
+		{"v3 ack", decodeEventV3(&v3api.StreamDeviceEventLogsResponse{Type: "ack", PayloadJson: `{"acknowledged":true,"fCnt":12}`}),
+			DeviceEvent{Type: EventAck, DownlinkId: "12", Acknowledged: true}, false},
+		{"v3 txack", decodeEventV3(&v3api.StreamDeviceEventLogsResponse{Type: "txack", PayloadJson: `{"fCnt":12}`}),
+			DeviceEvent{Type: EventTxAck, DownlinkId: "12"}, false},
+		{"v3 invalid object", decodeEventV3(&v3api.StreamDeviceEventLogsResponse{Type: "up", PayloadJson: `{"objectJSON":"{"}`}),
+			DeviceEvent{Type: EventUp}, true},
+		{"v4 uplink", decodeEventV4(&v4api.LogItem{Description: "up", Body: `{
+			"time":"2024-05-01T08:30:00Z","object":{"temperature":21.5},"dr":3,"fCnt":7,"fPort":2,
+			"rxInfo":[{"gatewayId":"a","rssi":-80,"snr":9}],
+			"txInfo":{"frequency":868100000,"modulation":{"lora":{"bandwidth":125000,"spreadingFactor":9}}}}`}),
+			DeviceEvent{Type: EventUp, Time: received, Metadata: &RadioMetadata{GatewayId: "a", Rssi: -80, Snr: 9, Frequency: 868100000, DataRate: 3, SpreadingFactor: 9, FCnt: 7, FPort: 2}}, false},
+		{"v4 ack", decodeEventV4(&v4api.LogItem{Description: "ack", Body: `{"queueItemId":"5f1c","acknowledged":false}`}),
+			DeviceEvent{Type: EventAck, DownlinkId: "5f1c"}, false},
+		{"v4 txack", decodeEventV4(&v4api.LogItem{Description: "txack", Body: `{"queueItemId":"5f1c"}`}),
+			DeviceEvent{Type: EventTxAck, DownlinkId: "5f1c"}, false},
+		{"v4 invalid body", decodeEventV4(&v4api.LogItem{Description: "up", Body: `{`}),
+			DeviceEvent{Type: EventUp}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := tt.event
+			if tt.err {
+				if event.Err == nil {
+					t.Fatalf("expected decode error")
+				}
+				return
+			}
+			if event.Err != nil {
+				t.Fatalf("unexpected error: %v", event.Err)
+			}
+			if event.Type != tt.expected.Type || event.DownlinkId != tt.expected.DownlinkId || event.Acknowledged != tt.expected.Acknowledged {
+				t.Errorf("expected %+v, got %+v", tt.expected, event)
+			}
+			if !event.Time.Equal(tt.expected.Time) {
+				t.Errorf("expected time %s, got %s", tt.expected.Time, event.Time)
+			}
+			if tt.expected.Metadata != nil {
+				if event.Metadata == nil || *event.Metadata != *tt.expected.Metadata {
+					t.Errorf("expected metadata %+v, got %+v", tt.expected.Metadata, event.Metadata)
+				}
+				if event.Object["temperature"] != 21.5 {
+					t.Errorf("unexpected object %v", event.Object)
+				}
+			}
+		})
+	}
+}
@@ -1,115 +1,135 @@
-//go:build chirpstack3
-// +build chirpstack3
-
 package driver
 
 import (
 	"context"
 	"encoding/json"
-	"errors"
-	"fmt"
-	"io"
+	"strconv"
 	"time"
 
 	"github.com/brocaar/chirpstack-api/go/v3/as/external/api"
-	"github.com/edgexfoundry/device-lora-go/config"
-	sdkModels "github.com/edgexfoundry/device-sdk-go/v3/pkg/models"
-	"github.com/edgexfoundry/go-mod-core-contracts/v3/models"
 )
 
 type PayloadJson struct {
-	ApplicationId string    `json:"applicationID"`
-	DevEUI        string    `json:"devEUI"`
-	ObjectJSON    string    `json:"objectJSON"`
-	PublishedAt   time.Time `json:"publishedAt"`
+	ApplicationId string              `json:"applicationID"`
+	DevEUI        string              `json:"devEUI"`
+	ObjectJSON    string              `json:"objectJSON"`
+	PublishedAt   time.Time           `json:"publishedAt"`
+	RxInfo        []PayloadRxInfoJson `json:"rxInfo"`
+	TxInfo        PayloadTxInfoJson   `json:"txInfo"`
+	FCnt          uint32              `json:"fCnt"`
+	FPort         uint32              `json:"fPort"`
 }
 
-type Listener struct {
-	driver     *LoraDriver
-	config     config.ChirpStackConfig
-	DeviceName string
-	Stop       bool
-	stream     api.DeviceService_StreamEventLogsClient
+type PayloadRxInfoJson struct {
+	GatewayId string    `json:"gatewayID"`
+	Time      time.Time `json:"time"`
+	Rssi      int32     `json:"rssi"`
+	LoRaSNR   float64   `json:"loRaSNR"`
 }
 
-func (e *Listener) Listening(chirp *ChirpStack, ctx context.Context, DevEUI string) (err error) {
-	var device models.Device
-	if device, err = e.driver.sdk.GetDeviceByName(e.DeviceName); err != nil {
-		return err
-	}
+type PayloadTxInfoJson struct {
+	Frequency uint32 `json:"frequency"`
+	Dr        uint32 `json:"dr"`
+}
 
-	var ok bool
-	var deviceResource models.DeviceResource
-	var profile models.DeviceProfile
-	if profile, err = e.driver.sdk.GetProfileByName(device.ProfileName); err == nil {
-		// lorawan返回的是json对象数据，
-		if len(profile.DeviceResources) == 1 {
-			sourceName := profile.DeviceResources[0].Name
-			optional := profile.DeviceResources[0].Properties.Optional
-			if _, ok = optional[CODEC]; ok {
-				deviceResource, ok = e.driver.sdk.DeviceResource(e.DeviceName, sourceName)
-			}
+// receivedAt returns the time the uplink was received, publishedAt of the network server or else the gateway time
+func (p PayloadJson) receivedAt() time.Time {
+	if !p.PublishedAt.IsZero() {
+		return p.PublishedAt
+	}
+	for _, rxInfo := range p.RxInfo {
+		if !rxInfo.Time.IsZero() {
+			return rxInfo.Time
 		}
 	}
+	return time.Time{}
+}
 
-	if !ok {
-		return errors.New("device resource not found")
+// radioMetadata returns the link quality of the uplink received by the best gateway
+func (p PayloadJson) radioMetadata() *RadioMetadata {
+	metadata := &RadioMetadata{
+		Frequency: p.TxInfo.Frequency,
+		DataRate:  p.TxInfo.Dr,
+		FCnt:      p.FCnt,
+		FPort:     p.FPort,
+	}
+	// v3的上行事件不带调制参数，DR与SF的对应关系取决于地区（如US915的DR0为SF10），不上报SF
+	for i, rxInfo := range p.RxInfo {
+		if i == 0 || rxInfo.Rssi > metadata.Rssi {
+			metadata.GatewayId = rxInfo.GatewayId
+			metadata.Rssi = rxInfo.Rssi
+			metadata.Snr = rxInfo.LoRaSNR
+		}
 	}
+	return metadata
+}
 
-	client := api.NewDeviceServiceClient(chirp.conn)
-	if e.stream, err = client.StreamEventLogs(ctx, &api.StreamDeviceEventLogsRequest{
-		DevEui: DevEUI,
-	}); err == nil {
-		for {
-			resp, err := e.stream.Recv()
-			if err == io.EOF {
-				break
-			}
+type AckPayloadJson struct {
+	DevEUI       string `json:"devEUI"`
+	Acknowledged bool   `json:"acknowledged"`
+	FCnt         uint32 `json:"fCnt"`
+}
 
-			if e.Stop {
-				break
-			}
+type TxAckPayloadJson struct {
+	DevEUI    string `json:"devEUI"`
+	FCnt      uint32 `json:"fCnt"`
+	GatewayId string `json:"gatewayID"`
+}
 
-			if err != nil {
-				fmt.Printf("[listener] recv a err %v", err)
-				break
-			}
+// StreamEvents streams the event logs of a device
+func (c *ChirpStackV3) StreamEvents(ctx context.Context, DevEUI string) (func() (*DeviceEvent, error), error) {
+	stream, err := api.NewDeviceServiceClient(c.conn).StreamEventLogs(ctx, &api.StreamDeviceEventLogsRequest{
+		DevEui: DevEUI,
+	})
+	if err != nil {
+		return nil, err
+	}
 
-			// 没有收到有用数据，跳过执行
-			if resp == nil || resp.Type != "up" {
-				continue
-			}
+	return func() (*DeviceEvent, error) {
+		resp, err := stream.Recv()
+		if err != nil {
+			return nil, err
+		}
+		return decodeEventV3(resp), nil
+	}, nil
+}
 
-			var payloadJson PayloadJson
-			err = json.Unmarshal([]byte(resp.PayloadJson), &payloadJson)
-			if err != nil {
-				continue
-			}
+// decodeEventV3 decodes an event log, the downlinks of v3 are identified by their fCnt
+func decodeEventV3(resp *api.StreamDeviceEventLogsResponse) *DeviceEvent {
+	event := &DeviceEvent{
+		Type:    resp.Type,
+		Payload: resp.PayloadJson,
+	}
 
-			var commandValues []*sdkModels.CommandValue
-			commandValue, err := e.driver.NewResult(deviceResource, payloadJson.ObjectJSON)
-			if err != nil {
-				continue
-			}
-			commandValues = append(commandValues, commandValue)
+	switch resp.Type {
+	case EventAck:
+		// 确认下行的应答
+		var ack AckPayloadJson
+		if event.Err = json.Unmarshal([]byte(resp.PayloadJson), &ack); event.Err == nil {
+			event.DownlinkId = strconv.FormatUint(uint64(ack.FCnt), 10)
+			event.Acknowledged = ack.Acknowledged
+		}
+	case EventTxAck:
+		// 下行已由网关发送
+		var txAck TxAckPayloadJson
+		if event.Err = json.Unmarshal([]byte(resp.PayloadJson), &txAck); event.Err == nil {
+			event.DownlinkId = strconv.FormatUint(uint64(txAck.FCnt), 10)
+		}
+	case EventUp:
+		var payloadJson PayloadJson
+		if event.Err = json.Unmarshal([]byte(resp.PayloadJson), &payloadJson); event.Err != nil {
+			return event
+		}
 
-			asyncValues := &sdkModels.AsyncValues{
-				DeviceName:    e.DeviceName,
-				SourceName:    deviceResource.Name,
-				CommandValues: commandValues,
+		// codec解码后的json对象
+		event.Object = map[string]interface{}{}
+		if len(payloadJson.ObjectJSON) > 0 {
+			if event.Err = json.Unmarshal([]byte(payloadJson.ObjectJSON), &event.Object); event.Err != nil {
+				return event
 			}
-
-			fmt.Printf("[listener] Incoming reading received: device=%v msg=%v", e.DeviceName, resp.PayloadJson)
-
-			e.driver.AsyncCh <- asyncValues
 		}
+		event.Metadata = payloadJson.radioMetadata()
+		event.Time = payloadJson.receivedAt()
 	}
-	return
-}
-
-func (e *Listener) Cancel() {
-	e.Stop = true
-	if e.stream != nil {
-		e.stream.Context().Done()
-	}
+	return event
 }
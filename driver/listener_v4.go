@@ -1,96 +1,123 @@
-//go:build chirpstack4
-// +build chirpstack4
-
 package driver
 
 import (
 	"context"
-	"errors"
-	"fmt"
-	"io"
+	"encoding/json"
+	"time"
 
 	"github.com/chirpstack/chirpstack/api/go/v4/api"
-	"github.com/edgexfoundry/device-lora-go/config"
-	sdkModels "github.com/edgexfoundry/device-sdk-go/v3/pkg/models"
-	"github.com/edgexfoundry/go-mod-core-contracts/v3/models"
 )
 
-type Listener struct {
-	driver     *LoraDriver
-	config     config.ChirpStackConfig
-	DeviceName string
-	Stop       bool
-	stream     api.InternalService_StreamDeviceEventsClient
+type UplinkEventJson struct {
+	DeduplicationId string                 `json:"deduplicationId"`
+	Time            time.Time              `json:"time"`
+	Object          map[string]interface{} `json:"object"`
+	Dr              uint32                 `json:"dr"`
+	FCnt            uint32                 `json:"fCnt"`
+	FPort           uint32                 `json:"fPort"`
+	RxInfo          []RxInfoJson           `json:"rxInfo"`
+	TxInfo          TxInfoJson             `json:"txInfo"`
 }
 
-func (e *Listener) Listening(chirp *ChirpStack, ctx context.Context, DevEUI string) (err error) {
-	var device models.Device
-	if device, err = e.driver.sdk.GetDeviceByName(e.DeviceName); err != nil {
-		return err
-	}
+type RxInfoJson struct {
+	GatewayId string  `json:"gatewayId"`
+	Rssi      int32   `json:"rssi"`
+	Snr       float64 `json:"snr"`
+}
 
-	var ok bool
-	var deviceResource models.DeviceResource
-	var profile models.DeviceProfile
-	if profile, err = e.driver.sdk.GetProfileByName(device.ProfileName); err == nil {
-		// lorawan返回的是json对象数据，
-		if len(profile.DeviceResources) == 1 {
-			sourceName := profile.DeviceResources[0].Name
-			optional := profile.DeviceResources[0].Properties.Optional
-			if _, ok = optional[CODEC]; ok {
-				deviceResource, ok = e.driver.sdk.DeviceResource(e.DeviceName, sourceName)
-			}
-		}
-	}
+type TxInfoJson struct {
+	Frequency  uint32 `json:"frequency"`
+	Modulation struct {
+		Lora struct {
+			Bandwidth       uint32 `json:"bandwidth"`
+			SpreadingFactor uint32 `json:"spreadingFactor"`
+		} `json:"lora"`
+	} `json:"modulation"`
+}
 
-	if !ok {
-		return errors.New("device resource not found")
+// radioMetadata returns the link quality of the uplink received by the best gateway
+func (u UplinkEventJson) radioMetadata() *RadioMetadata {
+	metadata := &RadioMetadata{
+		Frequency:       u.TxInfo.Frequency,
+		DataRate:        u.Dr,
+		SpreadingFactor: u.TxInfo.Modulation.Lora.SpreadingFactor,
+		FCnt:            u.FCnt,
+		FPort:           u.FPort,
 	}
+	for i, rxInfo := range u.RxInfo {
+		if i == 0 || rxInfo.Rssi > metadata.Rssi {
+			metadata.GatewayId = rxInfo.GatewayId
+			metadata.Rssi = rxInfo.Rssi
+			metadata.Snr = rxInfo.Snr
+		}
+	}
+	return metadata
+}
 
-	client := api.NewInternalServiceClient(chirp.conn)
-	if e.stream, err = client.StreamDeviceEvents(ctx, &api.StreamDeviceEventsRequest{
-		DevEui: DevEUI,
-	}); err == nil {
-		for {
-			resp, err := e.stream.Recv()
-			if err == io.EOF {
-				break
-			}
-
-			if e.Stop {
-				break
-			}
+type AckEventJson struct {
+	QueueItemId  string `json:"queueItemId"`
+	Acknowledged bool   `json:"acknowledged"`
+	FCntDown     uint32 `json:"fCntDown"`
+}
 
-			if err != nil {
-				fmt.Printf("[listener] recv a err %v", err)
-				break
-			}
+type TxAckEventJson struct {
+	DownlinkId  uint32 `json:"downlinkId"`
+	QueueItemId string `json:"queueItemId"`
+	FCntDown    uint32 `json:"fCntDown"`
+	GatewayId   string `json:"gatewayId"`
+}
 
-			var commandValues []*sdkModels.CommandValue
-			commandValue, err := e.driver.NewResult(deviceResource, resp.Body)
-			if err != nil {
-				fmt.Printf("[listener] Incoming data ignored: %v", err)
-				continue
-			}
-			commandValues = append(commandValues, commandValue)
+// StreamEvents streams the device events
+func (c *ChirpStackV4) StreamEvents(ctx context.Context, DevEUI string) (func() (*DeviceEvent, error), error) {
+	stream, err := api.NewInternalServiceClient(c.conn).StreamDeviceEvents(ctx, &api.StreamDeviceEventsRequest{
+		DevEui: DevEUI,
+	})
+	if err != nil {
+		return nil, err
+	}
 
-			asyncValues := &sdkModels.AsyncValues{
-				DeviceName:    e.DeviceName,
-				SourceName:    deviceResource.Name,
-				CommandValues: commandValues,
-			}
+	return func() (*DeviceEvent, error) {
+		resp, err := stream.Recv()
+		if err != nil {
+			return nil, err
+		}
+		return decodeEventV4(resp), nil
+	}, nil
+}
 
-			fmt.Printf("[listener] Incoming reading received: device=%v msg=%v", e.DeviceName, resp.Body)
+// decodeEventV4 decodes a device event, the downlinks of v4 are identified by their queue item id
+func decodeEventV4(resp *api.LogItem) *DeviceEvent {
+	event := &DeviceEvent{
+		Type:    resp.Description,
+		Payload: resp.Body,
+	}
 
-			e.driver.AsyncCh <- asyncValues
+	switch resp.Description {
+	case EventAck:
+		// 确认下行的应答
+		var ack AckEventJson
+		if event.Err = json.Unmarshal([]byte(resp.Body), &ack); event.Err == nil {
+			event.DownlinkId = ack.QueueItemId
+			event.Acknowledged = ack.Acknowledged
+		}
+	case EventTxAck:
+		// 下行已由网关发送
+		var txAck TxAckEventJson
+		if event.Err = json.Unmarshal([]byte(resp.Body), &txAck); event.Err == nil {
+			event.DownlinkId = txAck.QueueItemId
+		}
+	case EventUp:
+		var uplink UplinkEventJson
+		if event.Err = json.Unmarshal([]byte(resp.Body), &uplink); event.Err != nil {
+			return event
 		}
-	}
-	return
-}
 
-func (e *Listener) Cancel() {
-	e.Stop = true
-	if e.stream != nil {
-		e.stream.Context().Done()
+		event.Object = uplink.Object
+		if event.Object == nil {
+			event.Object = map[string]interface{}{}
+		}
+		event.Metadata = uplink.radioMetadata()
+		event.Time = uplink.Time
 	}
+	return event
 }
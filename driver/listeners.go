@@ -0,0 +1,186 @@
+package driver
+
+import (
+	"context"
+	"errors"
+
+	sdkModels "github.com/edgexfoundry/device-sdk-go/v3/pkg/models"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/models"
+)
+
+type Listener struct {
+	driver     *LoraDriver
+	DeviceName string
+	ctx        context.Context
+	cancel     context.CancelFunc
+	done       chan struct{}
+}
+
+// startListener starts listening the uplink events of a device, a running listener of the same device is replaced.
+// Listeners are keyed by device name.
+func (driver *LoraDriver) startListener(chirp ChirpStack, ctx context.Context, deviceName string, DevEUI string) {
+	listenerCtx, cancel := context.WithCancel(context.Background())
+	listener := &Listener{
+		driver:     driver,
+		DeviceName: deviceName,
+		ctx:        listenerCtx,
+		cancel:     cancel,
+		done:       make(chan struct{}),
+	}
+
+	driver.listenersMutex.Lock()
+	if old, ok := driver.listeners[deviceName]; ok {
+		old.Cancel()
+	}
+	driver.listeners[deviceName] = listener
+	driver.listenersWg.Add(1)
+	driver.listenersMutex.Unlock()
+
+	go func() {
+		defer driver.listenersWg.Done()
+		defer close(listener.done)
+		if err := listener.Listening(chirp, ctx, DevEUI); err != nil {
+			driver.logger.Errorf("[listener] device %s listening failed: %v", deviceName, err)
+		}
+		driver.removeListener(listener)
+	}()
+}
+
+// removeListener removes a finished listener, a listener which has replaced it is kept
+func (driver *LoraDriver) removeListener(listener *Listener) {
+	driver.listenersMutex.Lock()
+	defer driver.listenersMutex.Unlock()
+
+	if driver.listeners[listener.DeviceName] == listener {
+		delete(driver.listeners, listener.DeviceName)
+	}
+}
+
+// stopListener stops the listener of a device and waits for its stream to finish
+func (driver *LoraDriver) stopListener(deviceName string) {
+	driver.listenersMutex.Lock()
+	listener, ok := driver.listeners[deviceName]
+	if ok {
+		delete(driver.listeners, deviceName)
+	}
+	driver.listenersMutex.Unlock()
+
+	if ok {
+		listener.Cancel()
+		<-listener.done
+	}
+}
+
+// hasListener reports whether a device is being listened
+func (driver *LoraDriver) hasListener(deviceName string) bool {
+	driver.listenersMutex.Lock()
+	defer driver.listenersMutex.Unlock()
+
+	_, ok := driver.listeners[deviceName]
+	return ok
+}
+
+// stopListeners stops all listeners and waits for their streams to finish
+func (driver *LoraDriver) stopListeners() {
+	driver.listenersMutex.Lock()
+	for deviceName, listener := range driver.listeners {
+		listener.Cancel()
+		delete(driver.listeners, deviceName)
+	}
+	driver.listenersMutex.Unlock()
+
+	driver.listenersWg.Wait()
+}
+
+// Cancel stops the listener, the event stream is closed immediately
+func (e *Listener) Cancel() {
+	e.cancel()
+}
+
+func (e *Listener) Listening(chirp ChirpStack, ctx context.Context, DevEUI string) (err error) {
+	var device models.Device
+	if device, err = e.driver.sdk.GetDeviceByName(e.DeviceName); err != nil {
+		return err
+	}
+
+	var ok bool
+	var profile models.DeviceProfile
+	if profile, err = e.driver.sdk.GetProfileByName(device.ProfileName); err == nil {
+		// lorawan返回的是json对象数据，
+		_, ok = findCodecResource(profile)
+	}
+
+	if !ok {
+		return errors.New("device resource not found")
+	}
+
+	backoff := &Backoff{Min: MinReconnectDelay, Max: MaxReconnectDelay}
+	superviseStream(e.ctx, e.driver.logger, e.DeviceName, backoff, func(reconnect bool) (func() error, error) {
+		if reconnect {
+			// 重新登录chirpstack
+			var err error
+			if ctx, err = chirp.Login(); err != nil {
+				return nil, err
+			}
+		}
+
+		receive, err := chirp.StreamEvents(streamContext(e.ctx, ctx), DevEUI)
+		if err != nil {
+			return nil, err
+		}
+
+		return func() error {
+			event, err := receive()
+			if err != nil {
+				return err
+			}
+			e.handleEvent(event)
+			return nil
+		}, nil
+	})
+	return nil
+}
+
+func (e *Listener) handleEvent(event *DeviceEvent) {
+	// 没有收到有用数据，跳过执行
+	if event == nil || e.ctx.Err() != nil {
+		return
+	}
+
+	if event.Err != nil {
+		e.driver.logger.Debugf("[listener] Incoming %s event ignored: %v", event.Type, event.Err)
+		return
+	}
+
+	switch event.Type {
+	case EventUp:
+	case EventAck:
+		e.driver.downlinks.Resolve(e.DeviceName, event.DownlinkId, ackStatus(event.Acknowledged))
+		return
+	case EventTxAck:
+		e.driver.downlinks.Sent(e.DeviceName, event.DownlinkId)
+		return
+	default:
+		return
+	}
+
+	sourceName, commandValues, err := e.driver.uplinkResults(e.DeviceName, event.Object, event.Metadata, event.Time)
+	if err != nil {
+		e.driver.logger.Debugf("[listener] Incoming data ignored: %v", err)
+		return
+	}
+
+	asyncValues := &sdkModels.AsyncValues{
+		DeviceName:    e.DeviceName,
+		SourceName:    sourceName,
+		CommandValues: commandValues,
+	}
+
+	e.driver.logger.Debugf("[listener] Incoming reading received: device=%v msg=%v", e.DeviceName, event.Payload)
+
+	e.driver.readings.Update(e.DeviceName, commandValues)
+	select {
+	case e.driver.AsyncCh <- asyncValues:
+	case <-e.ctx.Done():
+	}
+}
@@ -0,0 +1,36 @@
+package driver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+)
+
+func TestFailedListenerRemoved(t *testing.T) {
+	driver := &LoraDriver{sdk: &fakeSDK{}, logger: logger.NewMockClient(), listeners: make(map[string]*Listener)}
+
+	// 设备不存在时监听立即失败
+	driver.startListener(&fakeChirpStack{}, context.Background(), "sensor-1", "9d13b5893728d5f6")
+	driver.listenersWg.Wait()
+
+	if driver.hasListener("sensor-1") {
+		t.Error("expected the failed listener removed")
+	}
+}
+
+func TestReplacedListenerKept(t *testing.T) {
+	old := &Listener{DeviceName: "sensor-1"}
+	current := &Listener{DeviceName: "sensor-1"}
+	driver := &LoraDriver{listeners: map[string]*Listener{"sensor-1": current}}
+
+	driver.removeListener(old)
+	if driver.listeners["sensor-1"] != current {
+		t.Error("expected the replacing listener kept")
+	}
+
+	driver.removeListener(current)
+	if driver.hasListener("sensor-1") {
+		t.Error("expected the listener removed")
+	}
+}
@@ -2,55 +2,108 @@ package driver
 
 import (
 	"context"
-	"errors"
 	"fmt"
 
 	"github.com/edgexfoundry/go-mod-core-contracts/v3/models"
 )
 
-func (driver *LoraDriver) AddLoraDevice(chirp *ChirpStack, device models.Device, profile models.DeviceProfile, protocolParams LoraProtocolParams) (err error) {
+func (driver *LoraDriver) AddLoraDevice(chirp ChirpStack, device models.Device, profile models.DeviceProfile, protocolParams LoraProtocolParams) (err error) {
 	// 登录chirpstack
 	var ctx context.Context
 	if ctx, err = chirp.Login(); err != nil {
 		return
 	}
 
-	var profileId string
-	// lorawan返回的是json对象数据，
-	if len(profile.DeviceResources) == 1 {
-		optional := profile.DeviceResources[0].Properties.Optional
-		if _, ok := optional[CODEC]; !ok {
-			return errors.New("optional codec not exists")
-		}
-		codec := fmt.Sprintf("%v", optional[CODEC])
+	if protocolParams.Gateway {
+		// 创建网关，已存在的网关直接使用
+		err = chirp.CreateGateway(ctx, protocolParams.EUI, device.Name)
+		return
+	}
+
+	// EdgeX中锁定的设备在chirpstack中禁用，且不监听
+	disabled := device.AdminState == models.Locked
 
-		profileId, err = chirp.CreateProfile(ctx, profile.Name, codec)
+	// 已存在于chirpstack的设备(如自动发现的设备)保留其profile、密钥和会话，只同步名称和禁用状态
+	var exists bool
+	if exists, err = chirp.DeviceExists(ctx, protocolParams.EUI); err != nil {
+		return
 	}
+	if exists {
+		if err = chirp.UpdateDevice(ctx, protocolParams.EUI, device.Name, "", disabled); err != nil {
+			return
+		}
 
-	if protocolParams.Gateway {
-		// 创建网关
-		err = chirp.CreateGateway(ctx, protocolParams.EUI, device.Name)
-	} else {
-		// 创建设备
-		if err = chirp.CreateDevice(ctx, protocolParams.EUI, device.Name, profileId); err == nil {
-			// 激活设备
-			err = chirp.ActivateDevice(ctx, protocolParams.EUI, chirp.config.ActivateKey)
-
-			// 添加监听
-			listener := Listener{
-				driver:     driver,
-				DeviceName: device.Name,
-				config:     chirp.config,
-				Stop:       false,
-			}
-			driver.listeners[device.Name] = listener
-			go listener.Listening(chirp, ctx, protocolParams.EUI)
+		// 补充缺失的密钥或激活，如上次添加设备时激活失败
+		var provisioned bool
+		if provisioned, err = isLoraDeviceProvisioned(chirp, ctx, protocolParams); err == nil && !provisioned {
+			err = provisionLoraDevice(chirp, ctx, protocolParams)
+		}
+		if err == nil && !disabled {
+			driver.startListener(chirp, ctx, device.Name, protocolParams.EUI)
 		}
+		return
+	}
+	if protocolParams.Activation == ActivationOTAA && len(protocolParams.AppKey) == 0 {
+		return fmt.Errorf("%s not found in protocol properties or secret %s", LoraAppKey, device.Name)
+	}
+
+	var profileId string
+	if profileId, err = driver.createLoraProfile(chirp, ctx, profile, protocolParams); err != nil {
+		return
+	}
+
+	// 创建设备，激活失败时设备保留在chirpstack中，下次添加设备或对账时重新激活
+	if err = chirp.CreateDevice(ctx, protocolParams.EUI, device.Name, profileId, disabled); err != nil {
+		return
+	}
+	if err = provisionLoraDevice(chirp, ctx, protocolParams); err != nil {
+		return
+	}
+
+	// 添加监听
+	if !disabled {
+		driver.startListener(chirp, ctx, device.Name, protocolParams.EUI)
 	}
 	return
 }
 
-func (driver *LoraDriver) UpdateLoraDevice(chirp *ChirpStack, device models.Device, protocolParams LoraProtocolParams) (err error) {
+// isLoraDeviceProvisioned reports whether the root keys of an OTAA device are set or an ABP device is activated
+func isLoraDeviceProvisioned(chirp ChirpStack, ctx context.Context, protocolParams LoraProtocolParams) (bool, error) {
+	if protocolParams.Activation == ActivationOTAA {
+		return chirp.HasKeys(ctx, protocolParams.EUI)
+	}
+	return chirp.HasActivation(ctx, protocolParams.EUI)
+}
+
+// provisionLoraDevice sets the root keys of an OTAA device or activates an ABP device
+func provisionLoraDevice(chirp ChirpStack, ctx context.Context, protocolParams LoraProtocolParams) (err error) {
+	if protocolParams.Activation == ActivationOTAA {
+		// OTAA设备创建根密钥，由设备发起入网，LoRaWAN 1.0.x的AppKey在ChirpStack中填写为nwkKey
+		if len(protocolParams.AppKey) == 0 {
+			return fmt.Errorf("%s not found", LoraAppKey)
+		}
+		if len(protocolParams.NwkKey) == 0 {
+			return chirp.CreateKeys(ctx, protocolParams.EUI, "", protocolParams.AppKey)
+		}
+		return chirp.CreateKeys(ctx, protocolParams.EUI, protocolParams.AppKey, protocolParams.NwkKey)
+	}
+
+	// ABP激活设备，未指定的会话密钥使用secret中的activateKey
+	key := chirp.Credentials().ActivateKey
+	appSKey, nwkSEncKey, sNwkSIntKey, fNwkSIntKey := key, key, key, key
+	if len(protocolParams.AppSKey) > 0 {
+		appSKey = protocolParams.AppSKey
+	}
+	if len(protocolParams.NwkSEncKey) > 0 {
+		nwkSEncKey, sNwkSIntKey, fNwkSIntKey = protocolParams.NwkSEncKey, protocolParams.SNwkSIntKey, protocolParams.FNwkSIntKey
+	}
+	if len(key) == 0 && (len(appSKey) == 0 || len(nwkSEncKey) == 0) {
+		return fmt.Errorf("%s and %s not found and the ChirpStack secret has no %s", LoraAppSKey, LoraNwkSEncKey, SecretActivateKey)
+	}
+	return chirp.ActivateDevice(ctx, protocolParams.EUI, protocolParams.DevAddr, appSKey, nwkSEncKey, sNwkSIntKey, fNwkSIntKey)
+}
+
+func (driver *LoraDriver) UpdateLoraDevice(chirp ChirpStack, device models.Device, protocolParams LoraProtocolParams) (err error) {
 	// 登录chirpstack
 	var ctx context.Context
 	if ctx, err = chirp.Login(); err != nil {
@@ -62,22 +115,21 @@ func (driver *LoraDriver) UpdateLoraDevice(chirp *ChirpStack, device models.Devi
 		err = chirp.UpdateGateway(ctx, protocolParams.EUI, device.Name)
 	} else {
 		// 更新设备
-		if err = chirp.UpdateDevice(ctx, protocolParams.EUI, device.Name); err == nil {
-			// 更新监听，主要是EUI的变化，这里检测不到EUI的变化，简单处理
-			if listener, ok := driver.listeners[device.Name]; ok {
-				// 删除旧的监听
-				listener.Cancel()
-				delete(driver.listeners, device.Name)
-
-				// 添加新的监听
-				listener := Listener{
-					driver:     driver,
-					DeviceName: device.Name,
-					config:     chirp.config,
-					Stop:       false,
-				}
-				driver.listeners[device.Name] = listener
-				go listener.Listening(chirp, ctx, protocolParams.EUI)
+		// 锁定的设备在chirpstack中禁用并停止监听，解锁后重新启用并监听
+		// chirpstack更新失败时锁定的设备也停止监听
+		disabled := device.AdminState == models.Locked
+		err = chirp.UpdateDevice(ctx, protocolParams.EUI, device.Name, "", disabled)
+		if disabled {
+			driver.stopListener(device.Name)
+		} else if err == nil {
+			// 更新监听，主要是EUI的变化，这里检测不到EUI的变化，简单处理，替换旧的监听
+			driver.startListener(chirp, ctx, device.Name, protocolParams.EUI)
+		}
+
+		// profile更新时SDK对其每个设备调用UpdateDevice，同步profile的codec和LoRaWAN参数，未修改时不访问chirpstack
+		if err == nil {
+			if err := driver.syncProfileByName(chirp, ctx, device.ProfileName, protocolParams.Activation); err != nil {
+				driver.logger.Warnf("Sync device profile %s to ChirpStack failed: %v", device.ProfileName, err)
 			}
 		}
 	}
@@ -85,7 +137,7 @@ func (driver *LoraDriver) UpdateLoraDevice(chirp *ChirpStack, device models.Devi
 	return
 }
 
-func (driver *LoraDriver) RemoveLoraDevice(chirp *ChirpStack, deviceName string, protocolParams LoraProtocolParams) (err error) {
+func (driver *LoraDriver) RemoveLoraDevice(chirp ChirpStack, deviceName string, protocolParams LoraProtocolParams) (err error) {
 	// 登录chirpstack
 	var ctx context.Context
 	if ctx, err = chirp.Login(); err != nil {
@@ -99,15 +151,48 @@ func (driver *LoraDriver) RemoveLoraDevice(chirp *ChirpStack, deviceName string,
 		// 删除设备
 		if err = chirp.DeleteDevice(ctx, deviceName, protocolParams.EUI); err == nil {
 			// 删除监听
-			if listener, ok := driver.listeners[deviceName]; ok {
-				listener.Cancel()
-				delete(driver.listeners, deviceName)
-			}
+			driver.stopListener(deviceName)
+			driver.readings.Remove(deviceName)
 		}
 	}
 
-	// 删除设备profile，codec转移到profile里面后由于取不到profileName，所以无法删除profile
-	// driver.deleteProfile(ctx, driver.tenantId, profileName)
+	// 删除不再使用的设备profile，设备已从SDK缓存中删除，其profile仍在缓存中
+	if err == nil && !protocolParams.Gateway {
+		driver.cleanupProfiles(chirp, ctx, driver.sdk.Devices())
+	}
+
+	return
+}
+
+func (driver *LoraDriver) ListLoraDeviceQueue(chirp ChirpStack, protocolParams LoraProtocolParams) (items []QueueItem, err error) {
+	// 登录chirpstack
+	var ctx context.Context
+	if ctx, err = chirp.Login(); err != nil {
+		return
+	}
+
+	items, err = chirp.ListQueue(ctx, protocolParams.EUI)
+	return
+}
+
+func (driver *LoraDriver) CountLoraDeviceQueue(chirp ChirpStack, protocolParams LoraProtocolParams) (count uint32, err error) {
+	// 登录chirpstack
+	var ctx context.Context
+	if ctx, err = chirp.Login(); err != nil {
+		return
+	}
+
+	count, err = chirp.CountQueue(ctx, protocolParams.EUI)
+	return
+}
+
+func (driver *LoraDriver) FlushLoraDeviceQueue(chirp ChirpStack, protocolParams LoraProtocolParams) (err error) {
+	// 登录chirpstack
+	var ctx context.Context
+	if ctx, err = chirp.Login(); err != nil {
+		return
+	}
 
+	err = chirp.FlushQueue(ctx, protocolParams.EUI)
 	return
 }
@@ -0,0 +1,47 @@
+package driver
+
+import (
+	"testing"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/models"
+)
+
+func TestAddExistingLoraDevice(t *testing.T) {
+	tests := []struct {
+		name      string
+		activated bool
+		calls     []string
+	}{
+		{"activated device", true, []string{"update device 9d13b5893728d5f6 sensor-1 disabled"}},
+		{"device not activated", false, []string{"update device 9d13b5893728d5f6 sensor-1 disabled", "activate device 9d13b5893728d5f6"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			chirp := &fakeChirpStack{
+				activated: map[string]bool{"9d13b5893728d5f6": test.activated},
+				devices:   []DeviceInfo{{DevEUI: "9d13b5893728d5f6", Name: "sensor-1"}},
+			}
+			driver := &LoraDriver{logger: logger.NewMockClient(), listeners: make(map[string]*Listener)}
+
+			// 锁定的设备不监听
+			device := models.Device{Name: "sensor-1", AdminState: models.Locked}
+			protocolParams := LoraProtocolParams{EUI: "9d13b5893728d5f6", Activation: ActivationABP}
+			if err := driver.AddLoraDevice(chirp, device, models.DeviceProfile{}, protocolParams); err != nil {
+				t.Fatal(err)
+			}
+			if len(chirp.calls) != len(test.calls) {
+				t.Fatalf("expected calls %v, got %v", test.calls, chirp.calls)
+			}
+			for i := range test.calls {
+				if chirp.calls[i] != test.calls[i] {
+					t.Errorf("expected calls %v, got %v", test.calls, chirp.calls)
+				}
+			}
+			if driver.hasListener("sensor-1") {
+				t.Error("expected the locked device not listened")
+			}
+		})
+	}
+}
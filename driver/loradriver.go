@@ -24,9 +24,12 @@ package driver
 
 import (
 	"context"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/edgexfoundry/device-lora-go/config"
@@ -35,6 +38,8 @@ import (
 	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
 	"github.com/edgexfoundry/go-mod-core-contracts/v3/common"
 	"github.com/edgexfoundry/go-mod-core-contracts/v3/models"
+	gometrics "github.com/rcrowley/go-metrics"
+	"github.com/spf13/cast"
 )
 
 type LoraDriver struct {
@@ -42,14 +47,39 @@ type LoraDriver struct {
 	logger    logger.LoggingClient
 	AsyncCh   chan<- *sdkModels.AsyncValues
 	chirp     ChirpStack
-	listeners map[string]Listener
+	downlinks *DownlinkTracker
+	readings  *ReadingCache
+	maxAge    time.Duration
+
+	// EdgeX device profiles of the discovered devices keyed by ChirpStack device profile
+	profileMapping map[string]string
+	// LoRaWAN settings of the ChirpStack device profiles which the EdgeX device profiles don't set
+	profileDefaults config.ProfileDefaults
+
+	// reconciliation of EdgeX devices with ChirpStack
+	reconcileInterval time.Duration
+	orphanPolicy      string
+	reconcileDrift    gometrics.Gauge
+	reconciler        *periodicTask
+
+	// codec and LoRaWAN settings pushed to ChirpStack, hashes of the settings keyed by EdgeX device profile
+	profileSyncInterval time.Duration
+	profileSync         *periodicTask
+	profileHashes       map[string]string
+	profileHashesMutex  sync.Mutex
+
+	// listeners of device uplink events keyed by device name
+	listeners      map[string]*Listener
+	listenersMutex sync.Mutex
+	listenersWg    sync.WaitGroup
 }
 
 func (driver *LoraDriver) Initialize(sdk interfaces.DeviceServiceSDK) (err error) {
 	driver.sdk = sdk
 	driver.logger = sdk.LoggingClient()
 	driver.AsyncCh = sdk.AsyncValuesChannel()
-	driver.listeners = make(map[string]Listener)
+	driver.listeners = make(map[string]*Listener)
+	driver.profileHashes = make(map[string]string)
 
 	serviceConfig := &config.ServiceConfig{}
 
@@ -63,8 +93,73 @@ func (driver *LoraDriver) Initialize(sdk interfaces.DeviceServiceSDK) (err error
 		return fmt.Errorf("'ChirpStack' custom configuration validation failed: %s", err.Error())
 	}
 
-	driver.chirp = ChirpStack{
-		config: serviceConfig.ChirpStack,
+	// TLS证书从secret或文件读取，更新后新建的连接生效
+	var tlsStore *TLSStore
+	if serviceConfig.ChirpStack.UseTLS {
+		if tlsStore, err = driver.loadTLS(serviceConfig.ChirpStack); err != nil {
+			return fmt.Errorf("unable to load ChirpStack TLS certificates: %s", err.Error())
+		}
+	}
+
+	// 按配置的版本选择chirpstack api
+	if driver.chirp, err = NewChirpStack(serviceConfig.ChirpStack, tlsStore); err != nil {
+		return err
+	}
+
+	// 从secret store读取chirpstack账号和激活密钥
+	var credentials Credentials
+	if credentials, err = driver.loadCredentials(serviceConfig.ChirpStack); err != nil {
+		return err
+	}
+	driver.chirp.SetCredentials(credentials)
+	if err = driver.watchCredentials(serviceConfig.ChirpStack); err != nil {
+		return fmt.Errorf("unable to watch secret %s: %s", serviceConfig.ChirpStack.SecretName, err.Error())
+	}
+
+	downlinkTimeout := serviceConfig.ChirpStack.DownlinkTimeout
+	if len(downlinkTimeout) == 0 {
+		downlinkTimeout = DefaultDownlinkTimeout
+	}
+	var timeout time.Duration
+	if timeout, err = time.ParseDuration(downlinkTimeout); err != nil {
+		return fmt.Errorf("'ChirpStack' DownlinkTimeout invalid: %s", err.Error())
+	}
+	driver.downlinks = NewDownlinkTracker(driver, timeout)
+
+	driver.readings = NewReadingCache()
+	if len(serviceConfig.ChirpStack.ReadingMaxAge) > 0 {
+		if driver.maxAge, err = time.ParseDuration(serviceConfig.ChirpStack.ReadingMaxAge); err != nil {
+			return fmt.Errorf("'ChirpStack' ReadingMaxAge invalid: %s", err.Error())
+		}
+	}
+
+	driver.profileMapping = serviceConfig.ChirpStack.ProfileMapping
+	driver.profileDefaults = serviceConfig.ChirpStack.ProfileDefaults
+	if _, err = getProfileParameters(models.DeviceResource{}, driver.profileDefaults, ActivationABP); err != nil {
+		return fmt.Errorf("'ChirpStack' ProfileDefaults invalid: %s", err.Error())
+	}
+	if len(serviceConfig.ChirpStack.ProfileSyncInterval) > 0 {
+		if driver.profileSyncInterval, err = time.ParseDuration(serviceConfig.ChirpStack.ProfileSyncInterval); err != nil {
+			return fmt.Errorf("'ChirpStack' ProfileSyncInterval invalid: %s", err.Error())
+		}
+	}
+
+	if len(serviceConfig.ChirpStack.ReconcileInterval) > 0 {
+		if driver.reconcileInterval, err = time.ParseDuration(serviceConfig.ChirpStack.ReconcileInterval); err != nil {
+			return fmt.Errorf("'ChirpStack' ReconcileInterval invalid: %s", err.Error())
+		}
+	}
+	driver.orphanPolicy = strings.ToLower(serviceConfig.ChirpStack.OrphanPolicy)
+	if len(driver.orphanPolicy) == 0 {
+		driver.orphanPolicy = OrphanPolicyReport
+	}
+
+	// 注册对账差异数指标，需在Writable.Telemetry.Metrics中开启
+	driver.reconcileDrift = gometrics.NewGauge()
+	if metricsManager := sdk.MetricsManager(); metricsManager != nil {
+		if err = metricsManager.Register(ReconcileDriftMetric, driver.reconcileDrift, nil); err != nil {
+			return fmt.Errorf("unable to register metric %s: %s", ReconcileDriftMetric, err.Error())
+		}
 	}
 
 	err = driver.chirp.Init()
@@ -80,26 +175,100 @@ func (driver *LoraDriver) Start() (err error) {
 	}
 
 	for _, device := range devices {
-		if protocolParams, err := getDeviceParameters(device.Protocols); err == nil && !protocolParams.Gateway {
-			//监听设备
-			listener := Listener{
-				driver:     driver,
-				DeviceName: device.Name,
-				config:     driver.chirp.config,
-				Stop:       false,
-			}
-			driver.listeners[protocolParams.EUI] = listener
-			go listener.Listening(&driver.chirp, ctx, protocolParams.EUI)
+		if protocolParams, err := getDeviceParameters(device.Protocols); err == nil && !protocolParams.Gateway && device.AdminState != models.Locked {
+			//监听设备，锁定的设备不监听
+			driver.startListener(driver.chirp, ctx, device.Name, protocolParams.EUI)
 		}
 	}
-	handler := NewLoraHandler(driver.sdk)
+
+	// 同步设备profile的codec和LoRaWAN参数，SDK没有profile的回调，按间隔检查profile缓存
+	driver.SyncProfiles()
+	if driver.profileSyncInterval > 0 {
+		driver.profileSync = startPeriodicTask(driver.profileSyncInterval, driver.SyncProfiles)
+	}
+
+	// 定期对账EdgeX与chirpstack的设备
+	if driver.reconcileInterval > 0 {
+		driver.reconciler = startPeriodicTask(driver.reconcileInterval, func() {
+			if _, err := driver.Reconcile(); err != nil {
+				driver.logger.Errorf("[reconciler] reconciliation failed: %v", err)
+			}
+		})
+	}
+
+	handler := NewLoraHandler(driver.sdk, driver)
 	return handler.Start()
 }
 
 func (driver *LoraDriver) HandleReadCommands(deviceName string, protocols map[string]models.ProtocolProperties, reqs []sdkModels.CommandRequest) (responses []*sdkModels.CommandValue, err error) {
-	driver.logger.Info("Lora not support HandleReadCommands function")
+	var protocolParams LoraProtocolParams
+	if protocolParams, err = getDeviceParameters(protocols); err != nil {
+		return nil, fmt.Errorf("Device parameters missing :%s \n", err.Error())
+	}
+
+	for _, req := range reqs {
+		deviceResource, ok := driver.sdk.DeviceResource(deviceName, req.DeviceResourceName)
+		if !ok {
+			return nil, fmt.Errorf("Incoming Reading ignored. Resource '%s' not found", req.DeviceResourceName)
+		}
+
+		var val interface{}
+		switch req.DeviceResourceName {
+		case QueueResource:
+			// 设备下行队列
+			var items []QueueItem
+			if items, err = driver.ListLoraDeviceQueue(driver.chirp, protocolParams); err != nil {
+				return nil, fmt.Errorf("List queue of %s failed: %s", protocolParams.EUI, err.Error())
+			}
+			if val, err = queueObject(items); err != nil {
+				return nil, err
+			}
+		case QueueCountResource:
+			// 设备下行队列长度
+			var count uint32
+			if count, err = driver.CountLoraDeviceQueue(driver.chirp, protocolParams); err != nil {
+				return nil, fmt.Errorf("Count queue of %s failed: %s", protocolParams.EUI, err.Error())
+			}
+			if val, err = validateCommandValue(deviceResource, count, deviceResource.Properties.ValueType, common.ContentTypeText); err != nil {
+				return nil, err
+			}
+		default:
+			// 返回最近一次上行的数据
+			var result *sdkModels.CommandValue
+			if result, err = driver.readings.Get(deviceName, req.DeviceResourceName, driver.maxAge); err != nil {
+				return nil, err
+			}
+			responses = append(responses, result)
+			continue
+		}
+
+		var result *sdkModels.CommandValue
+		if result, err = sdkModels.NewCommandValue(deviceResource.Name, deviceResource.Properties.ValueType, val); err != nil {
+			return nil, err
+		}
+		result.Origin = time.Now().UnixNano()
+		responses = append(responses, result)
+	}
+
+	return responses, nil
+}
 
-	return nil, fmt.Errorf("Lora not support HandleReadCommands function")
+// queueObject converts the queue items into the json object value of the queue resource
+func queueObject(items []QueueItem) (map[string]interface{}, error) {
+	buf, err := json.Marshal(items)
+	if err != nil {
+		return nil, err
+	}
+
+	var list []interface{}
+	if err = json.Unmarshal(buf, &list); err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"totalCount": len(items),
+		"items":      list,
+	}, nil
 }
 
 func (driver *LoraDriver) HandleWriteCommands(deviceName string, protocols map[string]models.ProtocolProperties, reqs []sdkModels.CommandRequest, params []*sdkModels.CommandValue) (err error) {
@@ -108,6 +277,12 @@ func (driver *LoraDriver) HandleWriteCommands(deviceName string, protocols map[s
 		return fmt.Errorf("Device parameters missing :%s \n", err.Error())
 	}
 
+	// 登录chirpstack
+	var ctx context.Context
+	if ctx, err = driver.chirp.Login(); err != nil {
+		return
+	}
+
 	for i, req := range reqs {
 		// First get device resource instance, needed during validation of the
 		// data received in the write command request
@@ -119,6 +294,21 @@ func (driver *LoraDriver) HandleWriteCommands(deviceName string, protocols map[s
 			return fmt.Errorf("Incoming Writing ignored. Resource '%s' not found", req.DeviceResourceName)
 		}
 
+		// 清空设备下行队列
+		if req.DeviceResourceName == FlushQueueResource {
+			var flush bool
+			if flush, err = cast.ToBoolE(params[i].Value); err != nil {
+				return fmt.Errorf("PUT request data is not valid")
+			}
+			if !flush {
+				continue
+			}
+			if err = driver.chirp.FlushQueue(ctx, protocolParams.EUI); err != nil {
+				return fmt.Errorf("Flush queue of %s failed: %s", protocolParams.EUI, err.Error())
+			}
+			continue
+		}
+
 		// Its time to form payload to be sent to end device.
 		// For this fisrt get the data received in the write command request
 		// This data is validated against the expected value type of device resource
@@ -132,6 +322,8 @@ func (driver *LoraDriver) HandleWriteCommands(deviceName string, protocols map[s
 			if !json.Valid([]byte(buf)) {
 				return fmt.Errorf("PUT request data is invalid JSON string")
 			}
+		case common.ValueTypeBinary:
+			// raw bytes are sent as they are
 		case common.ValueTypeBool, common.ValueTypeString, common.ValueTypeUint8,
 			common.ValueTypeUint16, common.ValueTypeUint32, common.ValueTypeUint64,
 			common.ValueTypeInt8, common.ValueTypeInt16, common.ValueTypeInt32,
@@ -148,12 +340,58 @@ func (driver *LoraDriver) HandleWriteCommands(deviceName string, protocols map[s
 			return fmt.Errorf("Unsupported value type: %v", valueType)
 		}
 
+		// 将写入的值编码为下行数据
+		var data []byte
+		var object string
+		if data, object, err = encodeDownlink(deviceResource, params[i]); err != nil {
+			return fmt.Errorf("Resource '%s' encode downlink failed: %s", req.DeviceResourceName, err.Error())
+		}
+
+		var fPort uint32
+		var confirmed bool
+		if fPort, confirmed, err = getDownlinkParameters(deviceResource); err != nil {
+			return fmt.Errorf("Resource '%s' downlink parameters invalid: %s", req.DeviceResourceName, err.Error())
+		}
+
 		driver.logger.Debugf("Send command to %s", protocolParams.EUI)
+		var id string
+		if id, err = driver.chirp.Enqueue(ctx, protocolParams.EUI, fPort, confirmed, data, object); err != nil {
+			return fmt.Errorf("Enqueue command to %s failed: %s", protocolParams.EUI, err.Error())
+		}
+
+		// 跟踪需要确认的下行，直到设备应答或超时
+		if confirmed {
+			driver.downlinks.Track(deviceName, id, req.DeviceResourceName)
+		}
 	}
 
 	return nil
 }
 
+// getDownlinkParameters reads the fPort and confirmed flag of a downlink from the device resource optional attributes
+func getDownlinkParameters(resource models.DeviceResource) (fPort uint32, confirmed bool, err error) {
+	fPort = DefaultFPort
+	optional := resource.Properties.Optional
+
+	if value, ok := optional[FPORT]; ok {
+		if fPort, err = cast.ToUint32E(value); err != nil {
+			return 0, false, fmt.Errorf("fPort is not a number: %v", value)
+		}
+		// LoRaWAN application ports are 1..223
+		if fPort < 1 || fPort > 223 {
+			return 0, false, fmt.Errorf("fPort %d out of range 1..223", fPort)
+		}
+	}
+
+	if value, ok := optional[CONFIRMED]; ok {
+		if confirmed, err = cast.ToBoolE(value); err != nil {
+			return 0, false, fmt.Errorf("confirmed is not bool type: %v", value)
+		}
+	}
+
+	return fPort, confirmed, nil
+}
+
 func getDeviceParameters(protocols map[string]models.ProtocolProperties) (LoraProtocolParams, error) {
 	var restDeviceProtocolParams LoraProtocolParams
 	protocolParams, paramsExists := protocols[LoraProtocol]
@@ -179,18 +417,120 @@ func getDeviceParameters(protocols map[string]models.ProtocolProperties) (LoraPr
 		return restDeviceProtocolParams, errors.New("LoraGateway not found")
 	}
 
+	// Get end device activation, ABP by default
+	restDeviceProtocolParams.Activation = ActivationABP
+	if activation, ok := protocolParams[LoraActivation]; ok {
+		restDeviceProtocolParams.Activation = strings.ToLower(fmt.Sprintf("%v", activation))
+	}
+
+	var err error
+	switch restDeviceProtocolParams.Activation {
+	case ActivationABP:
+		if !restDeviceProtocolParams.Gateway {
+			if err = getSessionParameters(protocolParams, &restDeviceProtocolParams); err != nil {
+				return restDeviceProtocolParams, err
+			}
+		}
+	case ActivationOTAA:
+		if !restDeviceProtocolParams.Gateway {
+			if restDeviceProtocolParams.AppKey, err = getHexParameter(protocolParams, LoraAppKey, 16, false); err != nil {
+				return restDeviceProtocolParams, err
+			}
+			if restDeviceProtocolParams.NwkKey, err = getHexParameter(protocolParams, LoraNwkKey, 16, false); err != nil {
+				return restDeviceProtocolParams, err
+			}
+		}
+	default:
+		return restDeviceProtocolParams, fmt.Errorf("activation %s is not supported, expected %s or %s",
+			restDeviceProtocolParams.Activation, ActivationOTAA, ActivationABP)
+	}
+
 	return restDeviceProtocolParams, nil
 }
 
+// getSessionParameters parses the optional ABP DevAddr and session keys, a LoRaWAN 1.0.x nwkSKey
+// is used for all network session keys while LoRaWAN 1.1 devices set the three split keys
+func getSessionParameters(protocolParams models.ProtocolProperties, params *LoraProtocolParams) (err error) {
+	if params.DevAddr, err = getHexParameter(protocolParams, LoraDevAddr, 4, false); err != nil {
+		return
+	}
+	if params.AppSKey, err = getHexParameter(protocolParams, LoraAppSKey, 16, false); err != nil {
+		return
+	}
+
+	var nwkSKey string
+	if nwkSKey, err = getHexParameter(protocolParams, LoraNwkSKey, 16, false); err != nil {
+		return
+	}
+	if params.NwkSEncKey, err = getHexParameter(protocolParams, LoraNwkSEncKey, 16, false); err != nil {
+		return
+	}
+	if params.SNwkSIntKey, err = getHexParameter(protocolParams, LoraSNwkSIntKey, 16, false); err != nil {
+		return
+	}
+	if params.FNwkSIntKey, err = getHexParameter(protocolParams, LoraFNwkSIntKey, 16, false); err != nil {
+		return
+	}
+
+	splitKeys := len(params.NwkSEncKey) > 0 || len(params.SNwkSIntKey) > 0 || len(params.FNwkSIntKey) > 0
+	if len(nwkSKey) > 0 {
+		if splitKeys {
+			return fmt.Errorf("%s can't be used together with %s, %s and %s", LoraNwkSKey, LoraNwkSEncKey, LoraSNwkSIntKey, LoraFNwkSIntKey)
+		}
+		params.NwkSEncKey, params.SNwkSIntKey, params.FNwkSIntKey = nwkSKey, nwkSKey, nwkSKey
+	} else if splitKeys && (len(params.NwkSEncKey) == 0 || len(params.SNwkSIntKey) == 0 || len(params.FNwkSIntKey) == 0) {
+		return fmt.Errorf("%s, %s and %s must be set together", LoraNwkSEncKey, LoraSNwkSIntKey, LoraFNwkSIntKey)
+	}
+	return nil
+}
+
+// getHexParameter returns a protocol param of size bytes as lower case hex characters, such as
+// a 32 bit DevAddr or a 128 bit key, the DevAddr may carry a 0x prefix
+func getHexParameter(protocolParams models.ProtocolProperties, name string, size int, required bool) (string, error) {
+	value, ok := protocolParams[name]
+	if !ok {
+		if required {
+			return "", fmt.Errorf("%s not found", name)
+		}
+		return "", nil
+	}
+
+	key, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("%s is not string type", name)
+	}
+	key = strings.TrimPrefix(strings.TrimPrefix(key, "0x"), "0X")
+	if decoded, err := hex.DecodeString(key); err != nil || len(decoded) != size {
+		return "", fmt.Errorf("%s must be %d hex characters", name, size*2)
+	}
+	return strings.ToLower(key), nil
+}
+
+// findCodecResource returns the device resource which carries the codec optional attribute,
+// lorawan uplinks are decoded by this codec into json object data
+func findCodecResource(profile models.DeviceProfile) (models.DeviceResource, bool) {
+	for _, resource := range profile.DeviceResources {
+		if _, ok := resource.Properties.Optional[CODEC]; ok {
+			return resource, true
+		}
+	}
+	return models.DeviceResource{}, false
+}
+
 func (driver *LoraDriver) Stop(force bool) error {
-	driver.logger.Debugf("RestDriver.Stop called: force=%v", force)
+	driver.logger.Debugf("LoraDriver.Stop called: force=%v", force)
+
+	// 停止对账和profile同步，关闭所有监听，并等待监听结束
+	driver.reconciler.Stop()
+	driver.profileSync.Stop()
+	driver.stopListeners()
 	return nil
 }
 
 func (driver *LoraDriver) AddDevice(deviceName string, protocols map[string]models.ProtocolProperties, adminState models.AdminState) (err error) {
 	driver.logger.Info("AddDevice %s", deviceName)
 	var protocolParams LoraProtocolParams
-	if protocolParams, err = getDeviceParameters(protocols); err != nil {
+	if protocolParams, err = driver.deviceParameters(deviceName, protocols); err != nil {
 		return fmt.Errorf("Device parameters missing :%s \n", err.Error())
 	}
 
@@ -204,7 +544,8 @@ func (driver *LoraDriver) AddDevice(deviceName string, protocols map[string]mode
 		return
 	}
 
-	err = driver.AddLoraDevice(&driver.chirp, device, profile, protocolParams)
+	device.AdminState = adminState
+	err = driver.AddLoraDevice(driver.chirp, device, profile, protocolParams)
 
 	return
 }
@@ -221,7 +562,8 @@ func (driver *LoraDriver) UpdateDevice(deviceName string, protocols map[string]m
 		return err
 	}
 
-	err = driver.UpdateLoraDevice(&driver.chirp, device, protocolParams)
+	device.AdminState = adminState
+	err = driver.UpdateLoraDevice(driver.chirp, device, protocolParams)
 
 	return
 }
@@ -233,18 +575,14 @@ func (driver *LoraDriver) RemoveDevice(deviceName string, protocols map[string]m
 		return fmt.Errorf("Device parameters missing :%s \n", err.Error())
 	}
 
-	err = driver.RemoveLoraDevice(&driver.chirp, deviceName, protocolParams)
+	err = driver.RemoveLoraDevice(driver.chirp, deviceName, protocolParams)
 
 	return
 }
 
-func (driver *LoraDriver) Discover() error {
-	return fmt.Errorf("driver's Discover function isn't implemented")
-}
-
 func (driver *LoraDriver) ValidateDevice(device models.Device) error {
 	if _, ok := device.Protocols[LoraProtocol]; ok {
-		_, err := getDeviceParameters(device.Protocols)
+		_, err := driver.deviceParameters(device.Name, device.Protocols)
 		if err != nil {
 			return fmt.Errorf("invalid protocol properties, %v", err)
 		}
@@ -262,8 +600,12 @@ func (driver *LoraDriver) NewResult(resource models.DeviceResource, reading inte
 	switch valueType {
 	case common.ValueTypeObject:
 		val = reading
-	default:
+	case common.ValueTypeBinary:
 		return nil, fmt.Errorf("return result fail, none supported value type: %v", valueType)
+	default:
+		if val, err = validateCommandValue(resource, reading, valueType, common.ContentTypeText); err != nil {
+			return nil, err
+		}
 	}
 
 	if result, err = sdkModels.NewCommandValue(resource.Name, valueType, val); err != nil {
@@ -37,19 +37,23 @@ import (
 )
 
 const (
-	apiResourceRoute  = common.ApiBase + "/resource/:deviceName/:resourceName"
-	handlerContextKey = "LoraHandler"
+	apiResourceRoute   = common.ApiBase + "/resource/:deviceName/:resourceName"
+	apiQueueRoute      = common.ApiBase + "/lora/:deviceName/queue"
+	apiQueueCountRoute = common.ApiBase + "/lora/:deviceName/queue/count"
+	handlerContextKey  = "LoraHandler"
 )
 
 type LoraHandler struct {
 	service     interfaces.DeviceServiceSDK
+	driver      *LoraDriver
 	logger      logger.LoggingClient
 	asyncValues chan<- *sdkModels.AsyncValues
 }
 
-func NewLoraHandler(sdk interfaces.DeviceServiceSDK) *LoraHandler {
+func NewLoraHandler(sdk interfaces.DeviceServiceSDK, driver *LoraDriver) *LoraHandler {
 	handler := LoraHandler{
 		service:     sdk,
+		driver:      driver,
 		logger:      sdk.LoggingClient(),
 		asyncValues: sdk.AsyncValuesChannel(),
 	}
@@ -68,6 +72,32 @@ func (handler LoraHandler) Start() error {
 
 	handler.logger.Infof("Route %s added.", apiResourceRoute)
 
+	if err := handler.service.AddCustomRoute(
+		apiQueueRoute,
+		interfaces.Authenticated,
+		handler.addContext(queueListHandler),
+		http.MethodGet); err != nil {
+		return fmt.Errorf("unable to add required route: %s: %s", apiQueueRoute, err.Error())
+	}
+
+	if err := handler.service.AddCustomRoute(
+		apiQueueRoute,
+		interfaces.Authenticated,
+		handler.addContext(queueFlushHandler),
+		http.MethodDelete); err != nil {
+		return fmt.Errorf("unable to add required route: %s: %s", apiQueueRoute, err.Error())
+	}
+
+	if err := handler.service.AddCustomRoute(
+		apiQueueCountRoute,
+		interfaces.Authenticated,
+		handler.addContext(queueCountHandler),
+		http.MethodGet); err != nil {
+		return fmt.Errorf("unable to add required route: %s: %s", apiQueueCountRoute, err.Error())
+	}
+
+	handler.logger.Infof("Route %s and %s added.", apiQueueRoute, apiQueueCountRoute)
+
 	return nil
 }
 
@@ -165,6 +195,90 @@ func deviceHandler(c echo.Context) error {
 	return handler.processAsyncRequest(c)
 }
 
+// deviceProtocolParams returns the lora protocol parameters of the device in the request path
+func (handler LoraHandler) deviceProtocolParams(c echo.Context) (LoraProtocolParams, error) {
+	deviceName := c.Param(common.DeviceName)
+
+	device, err := handler.service.GetDeviceByName(deviceName)
+	if err != nil {
+		return LoraProtocolParams{}, fmt.Errorf("Device '%s' not found", deviceName)
+	}
+
+	protocolParams, err := getDeviceParameters(device.Protocols)
+	if err != nil {
+		return LoraProtocolParams{}, fmt.Errorf("Device parameters missing :%s", err.Error())
+	}
+	if protocolParams.Gateway {
+		return LoraProtocolParams{}, fmt.Errorf("Device '%s' is a gateway", deviceName)
+	}
+
+	return protocolParams, nil
+}
+
+func queueListHandler(c echo.Context) error {
+	handler, ok := c.Request().Context().Value(handlerContextKey).(LoraHandler)
+	if !ok {
+		return c.String(http.StatusBadRequest, "Bad context pass to handler")
+	}
+
+	protocolParams, err := handler.deviceProtocolParams(c)
+	if err != nil {
+		return c.String(http.StatusNotFound, err.Error())
+	}
+
+	items, err := handler.driver.ListLoraDeviceQueue(handler.driver.chirp, protocolParams)
+	if err != nil {
+		handler.logger.Errorf("List queue of %s failed: %s", protocolParams.EUI, err.Error())
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+
+	value, err := queueObject(items)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, value)
+}
+
+func queueCountHandler(c echo.Context) error {
+	handler, ok := c.Request().Context().Value(handlerContextKey).(LoraHandler)
+	if !ok {
+		return c.String(http.StatusBadRequest, "Bad context pass to handler")
+	}
+
+	protocolParams, err := handler.deviceProtocolParams(c)
+	if err != nil {
+		return c.String(http.StatusNotFound, err.Error())
+	}
+
+	count, err := handler.driver.CountLoraDeviceQueue(handler.driver.chirp, protocolParams)
+	if err != nil {
+		handler.logger.Errorf("Count queue of %s failed: %s", protocolParams.EUI, err.Error())
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"totalCount": count})
+}
+
+func queueFlushHandler(c echo.Context) error {
+	handler, ok := c.Request().Context().Value(handlerContextKey).(LoraHandler)
+	if !ok {
+		return c.String(http.StatusBadRequest, "Bad context pass to handler")
+	}
+
+	protocolParams, err := handler.deviceProtocolParams(c)
+	if err != nil {
+		return c.String(http.StatusNotFound, err.Error())
+	}
+
+	if err = handler.driver.FlushLoraDeviceQueue(handler.driver.chirp, protocolParams); err != nil {
+		handler.logger.Errorf("Flush queue of %s failed: %s", protocolParams.EUI, err.Error())
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
 func validateCommandValue(resource model.DeviceResource, reading interface{}, valueType string, contentType string) (interface{}, error) {
 	var err error
 	castError := "failed to parse %v reading, %v"
@@ -0,0 +1,40 @@
+package driver
+
+import (
+	"context"
+	"time"
+)
+
+// periodicTask runs a function every interval in the background until it is stopped
+type periodicTask struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func startPeriodicTask(interval time.Duration, run func()) *periodicTask {
+	ctx, cancel := context.WithCancel(context.Background())
+	task := &periodicTask{cancel: cancel, done: make(chan struct{})}
+
+	go func() {
+		defer close(task.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				run()
+			}
+		}
+	}()
+	return task
+}
+
+// Stop stops the task and waits for a running call to finish, a nil task is ignored
+func (t *periodicTask) Stop() {
+	if t != nil {
+		t.cancel()
+		<-t.done
+	}
+}
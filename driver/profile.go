@@ -0,0 +1,301 @@
+package driver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/edgexfoundry/device-lora-go/config"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/models"
+	"github.com/spf13/cast"
+)
+
+// createLoraProfile returns the ChirpStack device profile of an EdgeX device profile, it is created with the codec
+// and the LoRaWAN settings of the codec resource when it doesn't exist
+func (driver *LoraDriver) createLoraProfile(chirp ChirpStack, ctx context.Context, profile models.DeviceProfile, protocolParams LoraProtocolParams) (profileId string, err error) {
+	// lorawan返回的是json对象数据，
+	resource, ok := findCodecResource(profile)
+	if !ok {
+		return "", errors.New("optional codec not exists")
+	}
+
+	var params LoraProfileParams
+	if params, err = getProfileParameters(resource, driver.profileDefaults, protocolParams.Activation); err != nil {
+		return "", fmt.Errorf("profile %s LoRaWAN settings invalid: %s", profile.Name, err.Error())
+	}
+
+	// 已存在的profile直接复用，同一个profile的设备需使用相同的入网方式
+	if profileId, err = chirp.CreateProfile(ctx, profile.Name, params); err != nil {
+		return
+	}
+	// 已存在的profile可能是旧的codec，同步后再添加设备
+	if err := driver.syncProfile(chirp, ctx, profile.Name, params); err != nil {
+		driver.logger.Warnf("Sync device profile %s to ChirpStack failed: %v", profile.Name, err)
+	}
+	return profileId, nil
+}
+
+// SyncProfiles pushes the codec and LoRaWAN settings of the EdgeX device profiles of the lora devices to ChirpStack
+// and cleans up the unused ones. Edited profiles are pushed by UpdateDevice, which the SDK calls for every device of
+// an updated profile, this catches the edits it missed by comparing the profiles with the pushed settings.
+func (driver *LoraDriver) SyncProfiles() {
+	// 登录chirpstack
+	ctx, err := driver.chirp.Login()
+	if err != nil {
+		driver.logger.Errorf("Sync device profiles failed: %v", err)
+		return
+	}
+	devices := driver.sdk.Devices()
+	driver.syncProfiles(ctx, devices)
+	driver.cleanupProfiles(driver.chirp, ctx, devices)
+}
+
+func (driver *LoraDriver) syncProfiles(ctx context.Context, devices []models.Device) {
+	// profile的OTAA支持按其第一个设备的入网方式
+	activations := make(map[string]string)
+	var names []string
+	for _, device := range devices {
+		protocolParams, err := getDeviceParameters(device.Protocols)
+		if err != nil || protocolParams.Gateway {
+			continue
+		}
+		if _, ok := activations[device.ProfileName]; !ok {
+			activations[device.ProfileName] = protocolParams.Activation
+			names = append(names, device.ProfileName)
+		}
+	}
+
+	for _, name := range names {
+		if err := driver.syncProfileByName(driver.chirp, ctx, name, activations[name]); err != nil {
+			driver.logger.Errorf("Sync device profile %s to ChirpStack failed: %v", name, err)
+		}
+	}
+}
+
+// syncProfileByName pushes the settings of the EdgeX device profile of the name, profiles without codec are skipped
+func (driver *LoraDriver) syncProfileByName(chirp ChirpStack, ctx context.Context, name string, activation string) error {
+	profile, err := driver.sdk.GetProfileByName(name)
+	if err != nil {
+		return err
+	}
+	resource, ok := findCodecResource(profile)
+	if !ok {
+		return nil
+	}
+	params, err := getProfileParameters(resource, driver.profileDefaults, activation)
+	if err != nil {
+		return fmt.Errorf("LoRaWAN settings invalid: %s", err.Error())
+	}
+	return driver.syncProfile(chirp, ctx, name, params)
+}
+
+// syncProfile pushes the settings of a device profile to ChirpStack, nothing is done when the same settings have
+// already been pushed
+func (driver *LoraDriver) syncProfile(chirp ChirpStack, ctx context.Context, name string, params LoraProfileParams) error {
+	hash := profileHash(params)
+	driver.profileHashesMutex.Lock()
+	pushed := driver.profileHashes[name] == hash
+	driver.profileHashesMutex.Unlock()
+	if pushed {
+		return nil
+	}
+
+	updated, err := chirp.UpdateProfile(ctx, name, params)
+	if err != nil {
+		return err
+	}
+	if updated {
+		driver.logger.Infof("Device profile %s updated in ChirpStack", name)
+	}
+
+	driver.profileHashesMutex.Lock()
+	driver.profileHashes[name] = hash
+	driver.profileHashesMutex.Unlock()
+	return nil
+}
+
+// cleanupProfiles deletes the ChirpStack device profiles of the EdgeX device profiles which no EdgeX device uses
+// anymore. The service names the ChirpStack profiles it creates after the EdgeX profiles with codec, so they are
+// matched by name. Profiles still used by devices of any
+// application of the ChirpStack tenant or organization are kept.
+// RemoveDevice runs it before the SDK drops the profile of the last device from its cache, core-metadata only
+// deletes unused profiles, so a deleted EdgeX profile has been cleaned up with its last device already.
+func (driver *LoraDriver) cleanupProfiles(chirp ChirpStack, ctx context.Context, devices []models.Device) {
+	// 统计每个profile的EdgeX设备数
+	users := make(map[string]int)
+	for _, device := range devices {
+		if _, ok := device.Protocols[LoraProtocol]; ok {
+			users[device.ProfileName]++
+		}
+	}
+
+	var unused []string
+	for _, profile := range driver.sdk.DeviceProfiles() {
+		if _, ok := findCodecResource(profile); ok && users[profile.Name] == 0 {
+			unused = append(unused, profile.Name)
+		}
+	}
+	sort.Strings(unused)
+
+	for _, name := range unused {
+		id, err := chirp.FindProfile(ctx, name)
+		if err != nil {
+			driver.logger.Errorf("Find device profile %s in ChirpStack failed: %v", name, err)
+			continue
+		}
+		if len(id) == 0 {
+			continue
+		}
+
+		// chirpstack中仍有设备使用的profile不删除，v4删除profile时会同时删除其设备
+		var used bool
+		if used, err = chirp.ProfileInUse(ctx, id); err != nil {
+			driver.logger.Errorf("Check device profile %s in ChirpStack failed: %v", name, err)
+			continue
+		}
+		if used {
+			driver.logger.Warnf("Device profile %s is still used by ChirpStack devices, it isn't deleted", name)
+			continue
+		}
+		if err = chirp.DeleteProfile(ctx, name); err != nil {
+			driver.logger.Errorf("Delete device profile %s from ChirpStack failed: %v", name, err)
+			continue
+		}
+		driver.logger.Infof("Device profile %s deleted from ChirpStack", name)
+
+		// 重新创建时需要再次同步
+		driver.profileHashesMutex.Lock()
+		delete(driver.profileHashes, name)
+		driver.profileHashesMutex.Unlock()
+	}
+}
+
+// profileHash is the hash of the codec and LoRaWAN settings of a device profile
+func profileHash(params LoraProfileParams) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%+v", params)))
+	return hex.EncodeToString(sum[:])
+}
+
+// getProfileParameters reads the LoRaWAN settings of a ChirpStack device profile from the codec resource optional
+// attributes, the settings which aren't set are taken from the defaults. The profile supports OTAA when the device
+// is activated by OTAA unless supportsOtaa is set.
+func getProfileParameters(resource models.DeviceResource, defaults config.ProfileDefaults, activation string) (params LoraProfileParams, err error) {
+	optional := resource.Properties.Optional
+	params = LoraProfileParams{
+		Region:            defaultString(defaults.Region, DefaultRegion),
+		MacVersion:        defaultString(defaults.MacVersion, DefaultMacVersion),
+		RegParamsRevision: defaultString(defaults.RegParamsRevision, DefaultRegParamsRevision),
+		MaxEirp:           defaults.MaxEirp,
+		SupportsOtaa:      activation == ActivationOTAA,
+		Rx1Delay:          defaults.Rx1Delay,
+		Rx2DataRate:       defaults.Rx2DataRate,
+		Rx2Frequency:      defaults.Rx2Frequency,
+		AdrAlgorithmId:    defaultString(defaults.AdrAlgorithmId, DefaultAdrAlgorithm),
+	}
+	if params.MaxEirp == 0 {
+		params.MaxEirp = DefaultMaxEirp
+	}
+	if codec, ok := optional[CODEC]; ok {
+		params.Codec = fmt.Sprintf("%v", codec)
+	}
+
+	// 枚举值
+	if value, ok := optional[REGION]; ok {
+		params.Region = fmt.Sprintf("%v", value)
+	}
+	if value, ok := optional[MACVERSION]; ok {
+		params.MacVersion = fmt.Sprintf("%v", value)
+	}
+	if value, ok := optional[REGPARAMSREVISION]; ok {
+		params.RegParamsRevision = fmt.Sprintf("%v", value)
+	}
+	if value, ok := optional[ADRALGORITHM]; ok {
+		params.AdrAlgorithmId = fmt.Sprintf("%v", value)
+	}
+	params.Region = strings.ToUpper(params.Region)
+	params.RegParamsRevision = strings.ToUpper(params.RegParamsRevision)
+	if err = validateEnum(REGION, params.Region, LoraRegions); err != nil {
+		return
+	}
+	if err = validateEnum(MACVERSION, params.MacVersion, LoraMacVersions); err != nil {
+		return
+	}
+	if err = validateEnum(REGPARAMSREVISION, params.RegParamsRevision, LoraRegParamsRevisions); err != nil {
+		return
+	}
+	if len(params.AdrAlgorithmId) == 0 {
+		return params, fmt.Errorf("%s can not be blank", ADRALGORITHM)
+	}
+
+	// 上行周期
+	uplinkInterval := defaultString(defaults.UplinkInterval, DefaultUplinkInterval)
+	if value, ok := optional[UPLINKINTERVAL]; ok {
+		uplinkInterval = fmt.Sprintf("%v", value)
+	}
+	if params.UplinkInterval, err = time.ParseDuration(uplinkInterval); err != nil || params.UplinkInterval < time.Second {
+		return params, fmt.Errorf("%s must be a duration of at least 1s: %s", UPLINKINTERVAL, uplinkInterval)
+	}
+
+	// 数值
+	uints := []struct {
+		name  string
+		value *uint32
+		max   uint32
+	}{
+		{MAXEIRP, &params.MaxEirp, 30},
+		{RX1DELAY, &params.Rx1Delay, 15},
+		{RX2DATARATE, &params.Rx2DataRate, 15},
+		{RX2FREQUENCY, &params.Rx2Frequency, 0},
+	}
+	for _, u := range uints {
+		if value, ok := optional[u.name]; ok {
+			if *u.value, err = cast.ToUint32E(value); err != nil {
+				return params, fmt.Errorf("%s is not a number: %v", u.name, value)
+			}
+		}
+		if u.max > 0 && *u.value > u.max {
+			return params, fmt.Errorf("%s %d out of range 0..%d", u.name, *u.value, u.max)
+		}
+	}
+
+	// 开关
+	bools := []struct {
+		name  string
+		value *bool
+	}{
+		{SUPPORTSOTAA, &params.SupportsOtaa},
+		{SUPPORTSCLASSB, &params.SupportsClassB},
+		{SUPPORTSCLASSC, &params.SupportsClassC},
+	}
+	for _, b := range bools {
+		if value, ok := optional[b.name]; ok {
+			if *b.value, err = cast.ToBoolE(value); err != nil {
+				return params, fmt.Errorf("%s is not bool type: %v", b.name, value)
+			}
+		}
+	}
+
+	return params, nil
+}
+
+// validateEnum checks that a setting is one of the allowed values
+func validateEnum(name string, value string, allowed []string) error {
+	for _, v := range allowed {
+		if v == value {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s %s is not supported, expected one of %s", name, value, strings.Join(allowed, ", "))
+}
+
+func defaultString(value string, defaultValue string) string {
+	if len(value) == 0 {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,171 @@
+package driver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/chirpstack/chirpstack/api/go/v4/common"
+	"github.com/edgexfoundry/device-lora-go/config"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/models"
+)
+
+func TestGetProfileParameters(t *testing.T) {
+	defaults := config.ProfileDefaults{Region: "eu868", UplinkInterval: "5m", Rx2Frequency: 869525000}
+
+	tests := []struct {
+		name       string
+		optional   map[string]any
+		activation string
+		expected   LoraProfileParams
+		err        bool
+	}{
+		{"defaults", map[string]any{CODEC: "decode"}, ActivationABP,
+			LoraProfileParams{Codec: "decode", Region: "EU868", MacVersion: "1.0.2", RegParamsRevision: "A", UplinkInterval: 5 * time.Minute,
+				MaxEirp: 20, Rx2Frequency: 869525000, AdrAlgorithmId: "default"}, false},
+		{"otaa device", map[string]any{}, ActivationOTAA,
+			LoraProfileParams{Region: "EU868", MacVersion: "1.0.2", RegParamsRevision: "A", UplinkInterval: 5 * time.Minute,
+				MaxEirp: 20, SupportsOtaa: true, Rx2Frequency: 869525000, AdrAlgorithmId: "default"}, false},
+		{"overrides", map[string]any{REGION: "CN470", MACVERSION: "1.0.3", REGPARAMSREVISION: "rp002-1.0.1", UPLINKINTERVAL: "1h",
+			MAXEIRP: "16", SUPPORTSOTAA: "false", SUPPORTSCLASSC: true, RX1DELAY: 5, RX2DATARATE: "3", RX2FREQUENCY: 505300000, ADRALGORITHM: "lr_fhss"}, ActivationOTAA,
+			LoraProfileParams{Region: "CN470", MacVersion: "1.0.3", RegParamsRevision: "RP002-1.0.1", UplinkInterval: time.Hour,
+				MaxEirp: 16, SupportsClassC: true, Rx1Delay: 5, Rx2DataRate: 3, Rx2Frequency: 505300000, AdrAlgorithmId: "lr_fhss"}, false},
+		{"unknown region", map[string]any{REGION: "EU869"}, ActivationABP, LoraProfileParams{}, true},
+		{"unknown mac version", map[string]any{MACVERSION: "1.2"}, ActivationABP, LoraProfileParams{}, true},
+		{"unknown regional parameters", map[string]any{REGPARAMSREVISION: "C"}, ActivationABP, LoraProfileParams{}, true},
+		{"invalid uplink interval", map[string]any{UPLINKINTERVAL: "600"}, ActivationABP, LoraProfileParams{}, true},
+		{"rx1Delay out of range", map[string]any{RX1DELAY: 16}, ActivationABP, LoraProfileParams{}, true},
+		{"invalid class B", map[string]any{SUPPORTSCLASSB: "sometimes"}, ActivationABP, LoraProfileParams{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resource := models.DeviceResource{Properties: models.ResourceProperties{Optional: tt.optional}}
+			params, err := getProfileParameters(resource, defaults, tt.activation)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", params)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if params != tt.expected {
+				t.Errorf("expected %+v, got %+v", tt.expected, params)
+			}
+		})
+	}
+}
+
+func TestProfileV3(t *testing.T) {
+	codec := "function Decode(fPort, bytes) { return {}; }\nfunction Encode(fPort, obj) { return []; }"
+	profile := profileV3("sensor", LoraProfileParams{Region: "CN470", MacVersion: "1.0.2", Codec: codec, SupportsOtaa: true})
+	if profile.PayloadCodec != "CUSTOM_JS" || profile.PayloadDecoderScript != codec || profile.PayloadEncoderScript != codec {
+		t.Errorf("expected the codec as decoder and encoder script, got %+v", profile)
+	}
+	if profile.RfRegion != "CN470" || profile.MacVersion != "1.0.2" || !profile.SupportsJoin {
+		t.Errorf("unexpected profile %+v", profile)
+	}
+}
+
+func TestProfileV4(t *testing.T) {
+	profile, err := profileV4("sensor", LoraProfileParams{Region: "AS923_2", MacVersion: "1.0.3", RegParamsRevision: "RP002-1.0.1", UplinkInterval: 10 * time.Minute})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if profile.Region != common.Region_AS923_2 || profile.MacVersion != common.MacVersion_LORAWAN_1_0_3 ||
+		profile.RegParamsRevision != common.RegParamsRevision_RP002_1_0_1 || profile.UplinkInterval != 600 {
+		t.Errorf("unexpected profile %+v", profile)
+	}
+}
+
+func TestSyncProfiles(t *testing.T) {
+	codecProfile := func(codec string) models.DeviceProfile {
+		return models.DeviceProfile{Name: "Lora-Device-CC10LD", DeviceResources: []models.DeviceResource{
+			{Name: "json", Properties: models.ResourceProperties{Optional: map[string]any{CODEC: codec}}},
+		}}
+	}
+	lora := func(eui string, gateway bool) map[string]models.ProtocolProperties {
+		return map[string]models.ProtocolProperties{LoraProtocol: {LoraEUI: eui, LoraGateway: gateway}}
+	}
+	devices := []models.Device{
+		{Name: "gateway-1", ProfileName: "Lora-Gateway", Protocols: lora("12c94daec6a7984d", true)},
+		{Name: "sensor-1", ProfileName: "Lora-Device-CC10LD", Protocols: lora("9d13b5893728d5f6", false)},
+		{Name: "sensor-2", ProfileName: "Lora-Device-CC10LD", Protocols: lora("9d13b5893728d5f7", false)},
+		{Name: "sensor-3", ProfileName: "Lora-Device-Missing", Protocols: lora("9d13b5893728d5f8", false)},
+	}
+
+	sdk := &fakeSDK{profiles: map[string]models.DeviceProfile{"Lora-Device-CC10LD": codecProfile("v1")}}
+	chirp := &fakeChirpStack{}
+	driver := &LoraDriver{
+		sdk:           sdk,
+		logger:        logger.NewMockClient(),
+		chirp:         chirp,
+		profileHashes: make(map[string]string),
+	}
+
+	steps := []struct {
+		name  string
+		codec string
+		calls []string
+	}{
+		{"first sync", "v1", []string{"update profile Lora-Device-CC10LD v1"}},
+		{"unchanged profile", "v1", nil},
+		{"edited codec", "v2", []string{"update profile Lora-Device-CC10LD v2"}},
+	}
+
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			sdk.profiles["Lora-Device-CC10LD"] = codecProfile(step.codec)
+			chirp.calls = nil
+			driver.syncProfiles(context.Background(), devices)
+			if len(chirp.calls) != len(step.calls) {
+				t.Fatalf("expected calls %v, got %v", step.calls, chirp.calls)
+			}
+			for i := range step.calls {
+				if chirp.calls[i] != step.calls[i] {
+					t.Errorf("expected calls %v, got %v", step.calls, chirp.calls)
+				}
+			}
+		})
+	}
+}
+
+func TestCleanupProfiles(t *testing.T) {
+	codecProfile := func(name string) models.DeviceProfile {
+		return models.DeviceProfile{Name: name, DeviceResources: []models.DeviceResource{
+			{Name: "json", Properties: models.ResourceProperties{Optional: map[string]any{CODEC: "v1"}}},
+		}}
+	}
+	devices := []models.Device{
+		{Name: "sensor-1", ProfileName: "Lora-Device-CC10LD", Protocols: map[string]models.ProtocolProperties{LoraProtocol: {LoraEUI: "9d13b5893728d5f6"}}},
+		{Name: "rest-device", ProfileName: "Lora-Device", Protocols: map[string]models.ProtocolProperties{"http": {}}},
+	}
+	sdk := &fakeSDK{profiles: map[string]models.DeviceProfile{
+		"Lora-Device-CC10LD": codecProfile("Lora-Device-CC10LD"),
+		"Lora-Device":        codecProfile("Lora-Device"),
+		"Lora-Device-CO2":    codecProfile("Lora-Device-CO2"),
+		"Lora-Device-New":    codecProfile("Lora-Device-New"),
+		"Lora-Gateway":       {Name: "Lora-Gateway"},
+	}}
+	chirp := &fakeChirpStack{
+		profiles: []string{"Lora-Device-CC10LD", "Lora-Device", "Lora-Device-CO2", "Lora-Gateway", "Other"},
+		devices:  []DeviceInfo{{DevEUI: "9d13b5893728d5f9", ProfileName: "Lora-Device-CO2"}},
+	}
+	driver := &LoraDriver{
+		sdk:           sdk,
+		logger:        logger.NewMockClient(),
+		profileHashes: map[string]string{"Lora-Device-CC10LD": "in use", "Lora-Device": "unused"},
+	}
+
+	driver.cleanupProfiles(chirp, context.Background(), devices)
+
+	if len(chirp.calls) != 1 || chirp.calls[0] != "delete profile Lora-Device" {
+		t.Errorf("expected only Lora-Device deleted, got %v", chirp.calls)
+	}
+	if len(driver.profileHashes) != 1 || driver.profileHashes["Lora-Device-CC10LD"] == "" {
+		t.Errorf("expected only Lora-Device-CC10LD pushed, got %v", driver.profileHashes)
+	}
+}
@@ -6,8 +6,37 @@
 
 package driver
 
+import "time"
+
 // LoraProtocolParams holds end device protocol parameters
 type LoraProtocolParams struct {
-	EUI     string // 设备EUI、网关EUI
-	Gateway bool   // 是否是网关设备
+	EUI        string // 设备EUI、网关EUI
+	Gateway    bool   // 是否是网关设备
+	Activation string // 入网方式：otaa、abp
+	AppKey     string // OTAA根密钥，LoRaWAN 1.0.x设备只需填写AppKey
+	NwkKey     string // OTAA网络根密钥，仅LoRaWAN 1.1设备使用
+
+	// ABP会话参数，为空时使用随机DevAddr和secret中的activateKey
+	DevAddr     string // 设备网络短地址
+	AppSKey     string // 应用会话密钥
+	NwkSEncKey  string // 网络会话加密密钥，LoRaWAN 1.0.x为NwkSKey
+	SNwkSIntKey string // 服务网络会话完整性密钥，LoRaWAN 1.0.x为NwkSKey
+	FNwkSIntKey string // 转发网络会话完整性密钥，LoRaWAN 1.0.x为NwkSKey
+}
+
+// LoraProfileParams holds the LoRaWAN settings of a ChirpStack device profile
+type LoraProfileParams struct {
+	Codec             string        // 上行解码脚本
+	Region            string        // 频段：EU868、CN470等
+	MacVersion        string        // LoRaWAN版本：1.0.2、1.0.3等
+	RegParamsRevision string        // 区域参数版本：A、B、RP002-1.0.0等
+	UplinkInterval    time.Duration // 上行周期
+	MaxEirp           uint32        // 最大发射功率，仅v3使用
+	SupportsOtaa      bool          // 是否支持OTAA入网
+	SupportsClassB    bool          // 是否支持Class B
+	SupportsClassC    bool          // 是否支持Class C
+	Rx1Delay          uint32        // ABP设备RX1延时(秒)，仅v4使用
+	Rx2DataRate       uint32        // ABP设备RX2数据速率，仅v4使用
+	Rx2Frequency      uint32        // ABP设备RX2频率(Hz)，仅v4使用
+	AdrAlgorithmId    string        // ADR算法
 }
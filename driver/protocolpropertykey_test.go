@@ -0,0 +1,98 @@
+package driver
+
+import (
+	"testing"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/models"
+)
+
+func TestGetDeviceParametersActivation(t *testing.T) {
+	const key = "2B7E151628AED2A6ABF7158809CF4F3C"
+
+	tests := []struct {
+		name       string
+		properties models.ProtocolProperties
+		activation string
+		appKey     string
+		nwkKey     string
+		err        bool
+	}{
+		{"abp by default", models.ProtocolProperties{}, ActivationABP, "", "", false},
+		{"otaa 1.0.x", models.ProtocolProperties{LoraActivation: "OTAA", LoraAppKey: key}, ActivationOTAA, "2b7e151628aed2a6abf7158809cf4f3c", "", false},
+		{"otaa 1.1", models.ProtocolProperties{LoraActivation: "otaa", LoraAppKey: key, LoraNwkKey: key}, ActivationOTAA, "2b7e151628aed2a6abf7158809cf4f3c", "2b7e151628aed2a6abf7158809cf4f3c", false},
+		{"otaa with appKey in secret", models.ProtocolProperties{LoraActivation: "otaa"}, ActivationOTAA, "", "", false},
+		{"short appKey", models.ProtocolProperties{LoraActivation: "otaa", LoraAppKey: "2b7e1516"}, "", "", "", true},
+		{"invalid nwkKey", models.ProtocolProperties{LoraActivation: "otaa", LoraAppKey: key, LoraNwkKey: "zz"}, "", "", "", true},
+		{"unknown activation", models.ProtocolProperties{LoraActivation: "join"}, "", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			properties := models.ProtocolProperties{LoraEUI: "9d13b5893728d5f6", LoraGateway: false}
+			for k, v := range tt.properties {
+				properties[k] = v
+			}
+
+			params, err := getDeviceParameters(map[string]models.ProtocolProperties{LoraProtocol: properties})
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", params)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if params.Activation != tt.activation || params.AppKey != tt.appKey || params.NwkKey != tt.nwkKey {
+				t.Errorf("unexpected params %+v", params)
+			}
+		})
+	}
+}
+
+func TestGetDeviceParametersSession(t *testing.T) {
+	const key = "2b7e151628aed2a6abf7158809cf4f3c"
+	const other = "000102030405060708090a0b0c0d0e0f"
+
+	tests := []struct {
+		name       string
+		properties models.ProtocolProperties
+		expected   LoraProtocolParams
+		err        bool
+	}{
+		{"random session", models.ProtocolProperties{}, LoraProtocolParams{}, false},
+		{"lorawan 1.0.x", models.ProtocolProperties{LoraDevAddr: "0x006D3D77", LoraAppSKey: key, LoraNwkSKey: other},
+			LoraProtocolParams{DevAddr: "006d3d77", AppSKey: key, NwkSEncKey: other, SNwkSIntKey: other, FNwkSIntKey: other}, false},
+		{"lorawan 1.1", models.ProtocolProperties{LoraAppSKey: key, LoraNwkSEncKey: other, LoraSNwkSIntKey: key, LoraFNwkSIntKey: other},
+			LoraProtocolParams{AppSKey: key, NwkSEncKey: other, SNwkSIntKey: key, FNwkSIntKey: other}, false},
+		{"short devAddr", models.ProtocolProperties{LoraDevAddr: "6d3d77"}, LoraProtocolParams{}, true},
+		{"short appSKey", models.ProtocolProperties{LoraAppSKey: "2b7e1516"}, LoraProtocolParams{}, true},
+		{"mixed nwkSKey", models.ProtocolProperties{LoraNwkSKey: key, LoraNwkSEncKey: key}, LoraProtocolParams{}, true},
+		{"partial split keys", models.ProtocolProperties{LoraNwkSEncKey: key, LoraSNwkSIntKey: key}, LoraProtocolParams{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			properties := models.ProtocolProperties{LoraEUI: "9d13b5893728d5f6", LoraGateway: false}
+			for k, v := range tt.properties {
+				properties[k] = v
+			}
+
+			params, err := getDeviceParameters(map[string]models.ProtocolProperties{LoraProtocol: properties})
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", params)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			tt.expected.EUI, tt.expected.Activation = params.EUI, ActivationABP
+			if params != tt.expected {
+				t.Errorf("expected %+v, got %+v", tt.expected, params)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/models"
+)
+
+// ReconcileSummary counts the differences a reconciliation found between EdgeX and ChirpStack
+type ReconcileSummary struct {
+	// Created are the devices and gateways missing in ChirpStack, Updated the name or profile mismatches
+	// and Provisioned the devices without keys or activation
+	Created     int
+	Updated     int
+	Provisioned int
+	// Orphans are the ChirpStack devices and gateways which aren't EdgeX devices, Deleted by the delete policy
+	Orphans int
+	Deleted int
+	Failed  int
+}
+
+// Drift is the number of differences found
+func (s ReconcileSummary) Drift() int {
+	return s.Created + s.Updated + s.Provisioned + s.Orphans
+}
+
+// Reconcile compares the EdgeX devices with the devices of the ChirpStack application and the gateways of the
+// tenant, the differences are fixed in ChirpStack and the orphan devices are reported or deleted by the orphan
+// policy. Orphan gateways may serve other applications of the tenant, they are only reported.
+func (driver *LoraDriver) Reconcile() (summary ReconcileSummary, err error) {
+	// 登录chirpstack
+	var ctx context.Context
+	if ctx, err = driver.chirp.Login(); err != nil {
+		return
+	}
+
+	var devices []DeviceInfo
+	if devices, err = driver.chirp.ListDevices(ctx); err != nil {
+		return summary, fmt.Errorf("List ChirpStack devices failed: %s", err.Error())
+	}
+	var gateways []GatewayInfo
+	if gateways, err = driver.chirp.ListGateways(ctx); err != nil {
+		return summary, fmt.Errorf("List ChirpStack gateways failed: %s", err.Error())
+	}
+
+	summary = driver.reconcile(ctx, driver.sdk.Devices(), devices, gateways)
+	if driver.reconcileDrift != nil {
+		driver.reconcileDrift.Update(int64(summary.Drift()))
+	}
+	driver.logger.Infof("[reconciler] created %d, updated %d, provisioned %d, orphans %d (deleted %d), failed %d",
+		summary.Created, summary.Updated, summary.Provisioned, summary.Orphans, summary.Deleted, summary.Failed)
+	return summary, nil
+}
+
+func (driver *LoraDriver) reconcile(ctx context.Context, edgexDevices []models.Device, devices []DeviceInfo, gateways []GatewayInfo) (summary ReconcileSummary) {
+	chirpDevices := make(map[string]DeviceInfo, len(devices))
+	for _, device := range devices {
+		chirpDevices[strings.ToLower(device.DevEUI)] = device
+	}
+	chirpGateways := make(map[string]GatewayInfo, len(gateways))
+	for _, gateway := range gateways {
+		chirpGateways[strings.ToLower(gateway.GatewayId)] = gateway
+	}
+
+	fail := func(device models.Device, action string, err error) {
+		summary.Failed++
+		driver.logger.Errorf("[reconciler] %s %s failed: %v", action, device.Name, err)
+	}
+
+	known := make(map[string]bool)
+	for _, device := range edgexDevices {
+		if _, ok := device.Protocols[LoraProtocol]; !ok {
+			continue
+		}
+		protocolParams, err := driver.deviceParameters(device.Name, device.Protocols)
+		if err != nil {
+			fail(device, "reconcile", err)
+			continue
+		}
+		eui := strings.ToLower(protocolParams.EUI)
+		known[eui] = true
+
+		if protocolParams.Gateway {
+			gateway, ok := chirpGateways[eui]
+			if !ok {
+				// 网关缺失时重新创建
+				summary.Created++
+				if err = driver.chirp.CreateGateway(ctx, protocolParams.EUI, device.Name); err != nil {
+					fail(device, "create gateway", err)
+				}
+			} else if gateway.Name != device.Name {
+				summary.Updated++
+				if err = driver.chirp.UpdateGateway(ctx, protocolParams.EUI, device.Name); err != nil {
+					fail(device, "update gateway", err)
+				}
+			}
+			continue
+		}
+
+		chirpDevice, ok := chirpDevices[eui]
+		if !ok {
+			// 设备缺失时重新添加
+			summary.Created++
+			var profile models.DeviceProfile
+			if profile, err = driver.sdk.GetProfileByName(device.ProfileName); err == nil {
+				err = driver.AddLoraDevice(driver.chirp, device, profile, protocolParams)
+			}
+			if err != nil {
+				fail(device, "create device", err)
+			}
+			continue
+		}
+
+		// 名称或profile不一致时更新，映射到EdgeX profile的chirpstack profile视为一致
+		var profileId string
+		if chirpDevice.ProfileName != device.ProfileName && driver.profileMapping[chirpDevice.ProfileName] != device.ProfileName {
+			var profile models.DeviceProfile
+			if profile, err = driver.sdk.GetProfileByName(device.ProfileName); err == nil {
+				profileId, err = driver.createLoraProfile(driver.chirp, ctx, profile, protocolParams)
+			}
+			if err != nil {
+				fail(device, "create profile of", err)
+			}
+		}
+		if chirpDevice.Name != device.Name || len(profileId) > 0 {
+			summary.Updated++
+			if err = driver.chirp.UpdateDevice(ctx, protocolParams.EUI, device.Name, profileId, device.AdminState == models.Locked); err != nil {
+				fail(device, "update device", err)
+			}
+		}
+
+		// 补充缺失的密钥或激活，如添加设备时激活失败
+		var provisioned bool
+		if provisioned, err = isLoraDeviceProvisioned(driver.chirp, ctx, protocolParams); err != nil {
+			fail(device, "check keys of", err)
+		} else if !provisioned {
+			summary.Provisioned++
+			if err = provisionLoraDevice(driver.chirp, ctx, protocolParams); err != nil {
+				fail(device, "provision device", err)
+			}
+		}
+	}
+
+	// 不属于EdgeX的设备按策略上报或删除
+	for eui, device := range chirpDevices {
+		if !known[eui] {
+			summary.Orphans++
+			driver.reconcileOrphan(&summary, "device", device.Name, device.DevEUI, func() error {
+				return driver.chirp.DeleteDevice(ctx, device.Name, device.DevEUI)
+			})
+		}
+	}
+	// 网关属于整个租户，可能服务于其他应用，只上报不删除
+	for eui, gateway := range chirpGateways {
+		if !known[eui] {
+			summary.Orphans++
+			driver.logger.Warnf("[reconciler] ChirpStack gateway %s (%s) isn't an EdgeX device, gateways aren't deleted", gateway.Name, gateway.GatewayId)
+		}
+	}
+
+	return summary
+}
+
+// reconcileOrphan reports an orphan, or deletes it with the delete policy
+func (driver *LoraDriver) reconcileOrphan(summary *ReconcileSummary, kind string, name string, eui string, remove func() error) {
+	if driver.orphanPolicy != OrphanPolicyDelete {
+		driver.logger.Warnf("[reconciler] ChirpStack %s %s (%s) isn't an EdgeX device", kind, name, eui)
+		return
+	}
+
+	if err := remove(); err != nil {
+		summary.Failed++
+		driver.logger.Errorf("[reconciler] delete ChirpStack %s %s (%s) failed: %v", kind, name, eui, err)
+		return
+	}
+	summary.Deleted++
+	driver.logger.Infof("[reconciler] ChirpStack %s %s (%s) deleted", kind, name, eui)
+}
@@ -0,0 +1,227 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+
+	"github.com/edgexfoundry/device-sdk-go/v3/pkg/interfaces"
+	bootstrapInterfaces "github.com/edgexfoundry/go-mod-bootstrap/v3/bootstrap/interfaces"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/models"
+)
+
+// fakeChirpStack records the calls of the reconciler, calls which aren't overridden panic
+type fakeChirpStack struct {
+	ChirpStack
+	activated map[string]bool
+	devices   []DeviceInfo
+	profiles  []string
+	calls     []string
+}
+
+func (c *fakeChirpStack) Credentials() Credentials {
+	return Credentials{ActivateKey: "bc67cd6eb45a08d975050b1887b93c23"}
+}
+
+func (c *fakeChirpStack) Login() (context.Context, error) {
+	return context.Background(), nil
+}
+
+func (c *fakeChirpStack) DeviceExists(ctx context.Context, DevEUI string) (bool, error) {
+	for _, device := range c.devices {
+		if device.DevEUI == DevEUI {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (c *fakeChirpStack) CreateGateway(ctx context.Context, gateWayId string, name string) error {
+	c.calls = append(c.calls, "create gateway "+gateWayId)
+	return nil
+}
+
+func (c *fakeChirpStack) UpdateGateway(ctx context.Context, gateWayId string, name string) error {
+	c.calls = append(c.calls, "update gateway "+gateWayId+" "+name)
+	return nil
+}
+
+func (c *fakeChirpStack) DeleteGateway(ctx context.Context, gateWayId string) error {
+	c.calls = append(c.calls, "delete gateway "+gateWayId)
+	return nil
+}
+
+func (c *fakeChirpStack) UpdateDevice(ctx context.Context, DevEUI string, name string, deviceProfileId string, disabled bool) error {
+	call := "update device " + DevEUI + " " + name
+	if disabled {
+		call += " disabled"
+	}
+	c.calls = append(c.calls, call)
+	return nil
+}
+
+func (c *fakeChirpStack) UpdateProfile(ctx context.Context, name string, params LoraProfileParams) (bool, error) {
+	c.calls = append(c.calls, "update profile "+name+" "+params.Codec)
+	return true, nil
+}
+
+func (c *fakeChirpStack) DeleteProfile(ctx context.Context, name string) error {
+	c.calls = append(c.calls, "delete profile "+name)
+	return nil
+}
+
+// FindProfile uses the name of the profile as its id
+func (c *fakeChirpStack) FindProfile(ctx context.Context, name string) (string, error) {
+	for _, profile := range c.profiles {
+		if profile == name {
+			return name, nil
+		}
+	}
+	return "", nil
+}
+
+func (c *fakeChirpStack) ProfileInUse(ctx context.Context, profileId string) (bool, error) {
+	for _, device := range c.devices {
+		if device.ProfileName == profileId {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (c *fakeChirpStack) ListDevices(ctx context.Context) ([]DeviceInfo, error) {
+	return c.devices, nil
+}
+
+func (c *fakeChirpStack) DeleteDevice(ctx context.Context, deviceName string, DevEUI string) error {
+	c.calls = append(c.calls, "delete device "+DevEUI)
+	return errors.New("permission denied")
+}
+
+func (c *fakeChirpStack) HasActivation(ctx context.Context, DevEUI string) (bool, error) {
+	return c.activated[DevEUI], nil
+}
+
+func (c *fakeChirpStack) ActivateDevice(ctx context.Context, DevEUI string, devAddr string, appSKey string, nwkSEncKey string, sNwkSIntKey string, fNwkSIntKey string) error {
+	c.calls = append(c.calls, "activate device "+DevEUI)
+	return nil
+}
+
+// fakeSDK has no device secrets, calls which aren't overridden panic
+type fakeSDK struct {
+	interfaces.DeviceServiceSDK
+	devices  map[string]models.Device
+	profiles map[string]models.DeviceProfile
+	secrets  map[string]map[string]string
+}
+
+func (s *fakeSDK) GetProfileByName(name string) (models.DeviceProfile, error) {
+	if profile, ok := s.profiles[name]; ok {
+		return profile, nil
+	}
+	return models.DeviceProfile{}, errors.New("profile not found")
+}
+
+func (s *fakeSDK) GetDeviceByName(name string) (models.Device, error) {
+	if device, ok := s.devices[name]; ok {
+		return device, nil
+	}
+	return models.Device{}, errors.New("device not found")
+}
+
+func (s *fakeSDK) DeviceProfiles() []models.DeviceProfile {
+	profiles := make([]models.DeviceProfile, 0, len(s.profiles))
+	for _, profile := range s.profiles {
+		profiles = append(profiles, profile)
+	}
+	return profiles
+}
+
+func (s *fakeSDK) SecretProvider() bootstrapInterfaces.SecretProvider {
+	return &fakeSecretProvider{secrets: s.secrets}
+}
+
+type fakeSecretProvider struct {
+	bootstrapInterfaces.SecretProvider
+	secrets map[string]map[string]string
+}
+
+func (p *fakeSecretProvider) GetSecret(secretName string, keys ...string) (map[string]string, error) {
+	if secrets, ok := p.secrets[secretName]; ok {
+		return secrets, nil
+	}
+	return nil, errors.New("secret not found")
+}
+
+func (p *fakeSecretProvider) HasSecret(secretName string) (bool, error) {
+	return false, nil
+}
+
+func TestReconcile(t *testing.T) {
+	lora := func(eui string, gateway bool) map[string]models.ProtocolProperties {
+		return map[string]models.ProtocolProperties{LoraProtocol: {LoraEUI: eui, LoraGateway: gateway}}
+	}
+	edgexDevices := []models.Device{
+		{Name: "gateway-1", Protocols: lora("12c94daec6a7984d", true)},
+		{Name: "gateway-2", Protocols: lora("12c94daec6a7984e", true)},
+		{Name: "sensor-1", ProfileName: "Lora-Device-CC10LD", Protocols: lora("9d13b5893728d5f6", false)},
+		{Name: "sensor-2", ProfileName: "Lora-Device-CC10LD", AdminState: models.Locked, Protocols: lora("9d13b5893728d5f7", false)},
+		{Name: "rest-device", Protocols: map[string]models.ProtocolProperties{"http": {}}},
+	}
+	devices := []DeviceInfo{
+		{DevEUI: "9d13b5893728d5f6", Name: "sensor-1", ProfileName: "cc10ld"},
+		{DevEUI: "9d13b5893728d5f7", Name: "old-name", ProfileName: "Lora-Device-CC10LD"},
+		{DevEUI: "9d13b5893728d5f8", Name: "orphan"},
+	}
+	gateways := []GatewayInfo{
+		{GatewayId: "12C94DAEC6A7984D", Name: "old-gateway"},
+		{GatewayId: "12c94daec6a7984f", Name: "orphan-gateway"},
+	}
+
+	tests := []struct {
+		name     string
+		policy   string
+		expected ReconcileSummary
+		calls    []string
+	}{
+		{"report orphans", OrphanPolicyReport,
+			ReconcileSummary{Created: 1, Updated: 2, Provisioned: 1, Orphans: 2},
+			[]string{"activate device 9d13b5893728d5f6", "create gateway 12c94daec6a7984e",
+				"update device 9d13b5893728d5f7 sensor-2 disabled", "update gateway 12c94daec6a7984d gateway-1"}},
+		{"delete orphan devices, report orphan gateways", OrphanPolicyDelete,
+			ReconcileSummary{Created: 1, Updated: 2, Provisioned: 1, Orphans: 2, Failed: 1},
+			[]string{"activate device 9d13b5893728d5f6", "create gateway 12c94daec6a7984e", "delete device 9d13b5893728d5f8",
+				"update device 9d13b5893728d5f7 sensor-2 disabled", "update gateway 12c94daec6a7984d gateway-1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chirp := &fakeChirpStack{activated: map[string]bool{"9d13b5893728d5f7": true}}
+			driver := &LoraDriver{
+				sdk:            &fakeSDK{},
+				logger:         logger.NewMockClient(),
+				chirp:          chirp,
+				profileMapping: map[string]string{"cc10ld": "Lora-Device-CC10LD"},
+				orphanPolicy:   tt.policy,
+			}
+
+			summary := driver.reconcile(context.Background(), edgexDevices, devices, gateways)
+			if summary != tt.expected {
+				t.Errorf("expected summary %+v, got %+v", tt.expected, summary)
+			}
+
+			sort.Strings(chirp.calls)
+			if len(chirp.calls) != len(tt.calls) {
+				t.Fatalf("expected calls %v, got %v", tt.calls, chirp.calls)
+			}
+			for i := range tt.calls {
+				if chirp.calls[i] != tt.calls[i] {
+					t.Errorf("expected calls %v, got %v", tt.calls, chirp.calls)
+					break
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package driver
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/edgexfoundry/device-lora-go/config"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/models"
+)
+
+// Credentials holds the ChirpStack login and the default ABP session key read from the secret store,
+// the v4 API token (from the secret or ChirpStack.ApiToken) replaces the username and password login when it is set
+type Credentials struct {
+	Username    string
+	Password    string
+	ApiToken    string
+	ActivateKey string
+}
+
+// CredentialStore keeps the credentials of ChirpStack, they are replaced whenever the secret is updated
+type CredentialStore struct {
+	mutex       sync.RWMutex
+	credentials Credentials
+}
+
+func (s *CredentialStore) Credentials() Credentials {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.credentials
+}
+
+func (s *CredentialStore) SetCredentials(credentials Credentials) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.credentials = credentials
+}
+
+// deviceSecretKeys are the protocol params which can be kept in the secret named after the device
+var deviceSecretKeys = []string{LoraAppKey, LoraNwkKey, LoraAppSKey, LoraNwkSKey, LoraNwkSEncKey, LoraSNwkSIntKey, LoraFNwkSIntKey}
+
+// loadCredentials reads the ChirpStack credentials from the secret store, the configured API token is used when
+// the secret has none. The activateKey is only needed to activate ABP devices without session keys.
+func (driver *LoraDriver) loadCredentials(chirpConfig config.ChirpStackConfig) (credentials Credentials, err error) {
+	secretName := chirpConfig.SecretName
+	var secrets map[string]string
+	if secrets, err = driver.sdk.SecretProvider().GetSecret(secretName); err != nil {
+		// 只配置了API token时可以没有secret
+		if len(chirpConfig.ApiToken) == 0 {
+			return credentials, fmt.Errorf("unable to read secret %s: %v", secretName, err)
+		}
+		return Credentials{ApiToken: chirpConfig.ApiToken}, nil
+	}
+
+	credentials = Credentials{
+		Username:    secrets[SecretUsername],
+		Password:    secrets[SecretPassword],
+		ApiToken:    secrets[SecretApiToken],
+		ActivateKey: secrets[SecretActivateKey],
+	}
+	if len(credentials.ApiToken) == 0 {
+		credentials.ApiToken = chirpConfig.ApiToken
+	}
+	if len(credentials.ApiToken) == 0 && (len(credentials.Username) == 0 || len(credentials.Password) == 0) {
+		return credentials, fmt.Errorf("secret %s requires %s or %s and %s, or ChirpStack.ApiToken", secretName, SecretApiToken, SecretUsername, SecretPassword)
+	}
+	return credentials, nil
+}
+
+// watchCredentials re-reads the ChirpStack credentials when the secret is updated, the next login uses them
+func (driver *LoraDriver) watchCredentials(chirpConfig config.ChirpStackConfig) error {
+	return driver.sdk.SecretProvider().RegisterSecretUpdatedCallback(chirpConfig.SecretName, func(secretName string) {
+		credentials, err := driver.loadCredentials(chirpConfig)
+		if err != nil {
+			driver.logger.Errorf("ChirpStack credentials not updated: %v", err)
+			return
+		}
+		driver.chirp.SetCredentials(credentials)
+		driver.logger.Infof("ChirpStack credentials updated from secret %s", secretName)
+	})
+}
+
+// loadTLS loads the TLS material of the ChirpStack connection, the TLS secret is watched and reloaded when it is updated
+func (driver *LoraDriver) loadTLS(chirpConfig config.ChirpStackConfig) (store *TLSStore, err error) {
+	secretName := chirpConfig.TLSSecretName
+	if len(secretName) == 0 {
+		return NewTLSStore(chirpConfig, nil)
+	}
+
+	provider := driver.sdk.SecretProvider()
+	if store, err = NewTLSStore(chirpConfig, func() (map[string]string, error) {
+		return provider.GetSecret(secretName)
+	}); err != nil {
+		return
+	}
+
+	err = provider.RegisterSecretUpdatedCallback(secretName, func(secretName string) {
+		if err := store.Reload(); err != nil {
+			driver.logger.Errorf("ChirpStack TLS certificates not updated: %v", err)
+			return
+		}
+		driver.logger.Infof("ChirpStack TLS certificates updated from secret %s", secretName)
+	})
+	return
+}
+
+// deviceParameters returns the protocol params of a device, the keys in the secret named after the device
+// take precedence over the keys in the protocol properties
+func (driver *LoraDriver) deviceParameters(deviceName string, protocols map[string]models.ProtocolProperties) (params LoraProtocolParams, err error) {
+	provider := driver.sdk.SecretProvider()
+	if exists, err := provider.HasSecret(deviceName); err == nil && exists {
+		var secrets map[string]string
+		if secrets, err = provider.GetSecret(deviceName); err != nil {
+			return params, fmt.Errorf("unable to read secret %s: %v", deviceName, err)
+		}
+
+		properties := models.ProtocolProperties{}
+		for key, value := range protocols[LoraProtocol] {
+			properties[key] = value
+		}
+		for _, key := range deviceSecretKeys {
+			if value, ok := secrets[key]; ok {
+				properties[key] = value
+			}
+		}
+
+		merged := make(map[string]models.ProtocolProperties, len(protocols))
+		for name, value := range protocols {
+			merged[name] = value
+		}
+		merged[LoraProtocol] = properties
+		protocols = merged
+	}
+
+	return getDeviceParameters(protocols)
+}
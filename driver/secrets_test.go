@@ -0,0 +1,52 @@
+package driver
+
+import (
+	"testing"
+
+	"github.com/edgexfoundry/device-lora-go/config"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+)
+
+func TestLoadCredentials(t *testing.T) {
+	tests := []struct {
+		name     string
+		secrets  map[string]string
+		apiToken string
+		expected Credentials
+		hasError bool
+	}{
+		{"username and password", map[string]string{SecretUsername: "admin", SecretPassword: "admin"}, "",
+			Credentials{Username: "admin", Password: "admin"}, false},
+		{"api token in secret", map[string]string{SecretApiToken: "secret-token", SecretActivateKey: "bc67cd6eb45a08d975050b1887b93c23"}, "config-token",
+			Credentials{ApiToken: "secret-token", ActivateKey: "bc67cd6eb45a08d975050b1887b93c23"}, false},
+		{"configured api token", map[string]string{SecretActivateKey: "bc67cd6eb45a08d975050b1887b93c23"}, "config-token",
+			Credentials{ApiToken: "config-token", ActivateKey: "bc67cd6eb45a08d975050b1887b93c23"}, false},
+		{"configured api token without secret", nil, "config-token", Credentials{ApiToken: "config-token"}, false},
+		{"no secret", nil, "", Credentials{}, true},
+		{"no login", map[string]string{SecretUsername: "admin", SecretActivateKey: "bc67cd6eb45a08d975050b1887b93c23"}, "", Credentials{}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sdk := &fakeSDK{secrets: map[string]map[string]string{}}
+			if test.secrets != nil {
+				sdk.secrets["chirpstack"] = test.secrets
+			}
+			driver := &LoraDriver{sdk: sdk, logger: logger.NewMockClient()}
+
+			credentials, err := driver.loadCredentials(config.ChirpStackConfig{SecretName: "chirpstack", ApiToken: test.apiToken})
+			if test.hasError {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if credentials != test.expected {
+				t.Errorf("expected %+v, got %+v", test.expected, credentials)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package driver
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// SessionRefreshMargin is how long before its expiry the jwt is refreshed
+var SessionRefreshMargin = time.Minute
+
+// LoginFunc logs in to ChirpStack and returns the jwt
+type LoginFunc func(username string, password string) (jwt string, err error)
+
+// Session keeps the ChirpStack jwt so that operations don't log in every time, the jwt is sent with every RPC.
+// It is refreshed before it expires and once more when ChirpStack rejects it, the API token of the
+// credentials replaces the jwt when it is set.
+type Session struct {
+	store *CredentialStore
+	login LoginFunc
+
+	// loginMutex serializes the logins, mutex guards the jwt which is read by every RPC
+	loginMutex sync.Mutex
+	mutex      sync.RWMutex
+	jwt        string
+	expires    time.Time
+}
+
+func NewSession(store *CredentialStore, login LoginFunc) *Session {
+	return &Session{
+		store: store,
+		login: login,
+	}
+}
+
+// token returns the API token or the current jwt
+func (s *Session) token() string {
+	if token := s.store.Credentials().ApiToken; len(token) > 0 {
+		return token
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.jwt
+}
+
+// Refresh logs in when there is no jwt yet or it is about to expire
+func (s *Session) Refresh() error {
+	s.mutex.RLock()
+	valid := len(s.jwt) > 0 && (s.expires.IsZero() || time.Until(s.expires) > SessionRefreshMargin)
+	s.mutex.RUnlock()
+
+	if valid || len(s.store.Credentials().ApiToken) > 0 {
+		return nil
+	}
+	return s.relogin("")
+}
+
+// Invalidate drops the jwt, the next RPC logs in with the current credentials
+func (s *Session) Invalidate() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.jwt = ""
+	s.expires = time.Time{}
+}
+
+// relogin logs in again unless the rejected jwt has been replaced by another RPC in the meantime
+func (s *Session) relogin(rejected string) error {
+	s.loginMutex.Lock()
+	defer s.loginMutex.Unlock()
+
+	s.mutex.RLock()
+	replaced := len(rejected) > 0 && s.jwt != rejected
+	s.mutex.RUnlock()
+	if replaced {
+		return nil
+	}
+
+	credentials := s.store.Credentials()
+	jwt, err := s.login(credentials.Username, credentials.Password)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.jwt = jwt
+	s.expires = jwtExpiry(jwt)
+	return nil
+}
+
+// GetRequestMetadata authorizes every RPC with the API token or the current jwt
+func (s *Session) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	token := s.token()
+	if len(token) == 0 {
+		return nil, nil
+	}
+	return map[string]string{
+		"authorization": "Bearer " + token,
+	}, nil
+}
+
+func (s *Session) RequireTransportSecurity() bool {
+	return false
+}
+
+// UnaryInterceptor retries a RPC once after logging in again when ChirpStack rejects the jwt
+func (s *Session) UnaryInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	token := s.token()
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	if status.Code(err) != codes.Unauthenticated || strings.HasSuffix(method, "/Login") || len(s.store.Credentials().ApiToken) > 0 {
+		return err
+	}
+
+	if loginErr := s.relogin(token); loginErr != nil {
+		return err
+	}
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
+
+// jwtExpiry returns the exp claim of a jwt, or zero time when the jwt can't be decoded
+func jwtExpiry(jwt string) time.Time {
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		return time.Time{}
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}
+	}
+
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err = json.Unmarshal(payload, &claims); err != nil || claims.Exp == 0 {
+		return time.Time{}
+	}
+	return time.Unix(claims.Exp, 0)
+}
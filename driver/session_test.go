@@ -0,0 +1,118 @@
+package driver
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func newJwt(expires time.Time) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"sub":"admin","exp":%d}`, expires.Unix())))
+	return "eyJhbGciOiJIUzI1NiJ9." + payload + ".c2lnbmF0dXJl"
+}
+
+func TestJwtExpiry(t *testing.T) {
+	expires := time.Now().Add(time.Hour).Truncate(time.Second)
+	if got := jwtExpiry(newJwt(expires)); !got.Equal(expires) {
+		t.Errorf("expected %s, got %s", expires, got)
+	}
+	if got := jwtExpiry("not-a-jwt"); !got.IsZero() {
+		t.Errorf("expected zero time, got %s", got)
+	}
+}
+
+func TestSessionRefresh(t *testing.T) {
+	logins := 0
+	expires := time.Now().Add(time.Hour)
+	store := &CredentialStore{}
+	store.SetCredentials(Credentials{Username: "admin", Password: "admin"})
+	session := NewSession(store, func(username string, password string) (string, error) {
+		logins++
+		return newJwt(expires), nil
+	})
+
+	for i := 0; i < 3; i++ {
+		if err := session.Refresh(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if logins != 1 {
+		t.Errorf("expected 1 login for a valid jwt, got %d", logins)
+	}
+
+	// the jwt is refreshed before it expires
+	expires = time.Now().Add(SessionRefreshMargin / 2)
+	session.relogin("")
+	if err := session.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	if logins != 3 {
+		t.Errorf("expected a login for an expiring jwt, got %d logins", logins)
+	}
+
+	// API tokens never log in
+	store.SetCredentials(Credentials{ApiToken: "token"})
+	session = NewSession(store, func(username string, password string) (string, error) {
+		t.Fatal("unexpected login")
+		return "", nil
+	})
+	if err := session.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	if md, _ := session.GetRequestMetadata(context.Background()); md["authorization"] != "Bearer token" {
+		t.Errorf("unexpected metadata %v", md)
+	}
+}
+
+func TestSessionRetryUnauthenticated(t *testing.T) {
+	logins := 0
+	store := &CredentialStore{}
+	store.SetCredentials(Credentials{Username: "admin", Password: "admin"})
+	session := NewSession(store, func(username string, password string) (string, error) {
+		logins++
+		return fmt.Sprintf("jwt-%d", logins), nil
+	})
+	if err := session.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		errs   []error
+		calls  int
+		logins int
+		code   codes.Code
+	}{
+		{"success", "/api.DeviceService/Get", []error{nil}, 1, 0, codes.OK},
+		{"retried once", "/api.DeviceService/Get", []error{status.Error(codes.Unauthenticated, "expired"), nil}, 2, 1, codes.OK},
+		{"rejected twice", "/api.DeviceService/Get", []error{status.Error(codes.Unauthenticated, "expired"), status.Error(codes.Unauthenticated, "expired")}, 2, 1, codes.Unauthenticated},
+		{"other error", "/api.DeviceService/Get", []error{status.Error(codes.NotFound, "not found")}, 1, 0, codes.NotFound},
+		{"login not retried", "/api.InternalService/Login", []error{status.Error(codes.Unauthenticated, "bad password")}, 1, 0, codes.Unauthenticated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls, before := 0, logins
+			invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				err := tt.errs[calls]
+				calls++
+				return err
+			}
+
+			err := session.UnaryInterceptor(context.Background(), tt.method, nil, nil, nil, invoker)
+			if status.Code(err) != tt.code {
+				t.Errorf("expected %s, got %v", tt.code, err)
+			}
+			if calls != tt.calls || logins-before != tt.logins {
+				t.Errorf("expected %d calls and %d logins, got %d and %d", tt.calls, tt.logins, calls, logins-before)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package driver
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"google.golang.org/grpc/metadata"
+)
+
+var (
+	MinReconnectDelay = time.Second
+	MaxReconnectDelay = 2 * time.Minute
+)
+
+// Backoff computes exponential reconnect delays with jitter
+type Backoff struct {
+	Min     time.Duration
+	Max     time.Duration
+	attempt uint
+}
+
+// Next returns the delay before the next reconnect, it doubles on every call up to Max,
+// the returned delay is randomized between half and the full delay
+func (b *Backoff) Next() time.Duration {
+	delay := b.Max
+	if b.attempt < 32 {
+		if d := b.Min << b.attempt; d > 0 && d < b.Max {
+			delay = d
+			b.attempt++
+		}
+	}
+
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(delay-half)+1)) //nolint:gosec
+}
+
+// Reset restarts the delays from Min
+func (b *Backoff) Reset() {
+	b.attempt = 0
+}
+
+// StreamOpener opens the event stream, reconnect is true when the stream is reopened after a failure.
+// It returns a receive function which blocks until the next event has been handled or the stream fails.
+type StreamOpener func(reconnect bool) (receive func() error, err error)
+
+// superviseStream keeps an event stream running, whenever the stream fails it is reopened with backoff
+// until ctx is cancelled
+func superviseStream(ctx context.Context, lc logger.LoggingClient, name string, backoff *Backoff, open StreamOpener) {
+	reconnect := false
+	for ctx.Err() == nil {
+		receive, err := open(reconnect)
+		if err == nil {
+			if reconnect {
+				lc.Infof("[listener] stream of %s reconnected", name)
+			}
+			for err == nil {
+				if err = receive(); err == nil {
+					backoff.Reset()
+				}
+			}
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		delay := backoff.Next()
+		lc.Warnf("[listener] stream of %s closed: %v, reconnecting in %s", name, err, delay)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		reconnect = true
+	}
+}
+
+// streamContext returns a context which carries the authorization of the login context
+// and is cancelled together with the listener context
+func streamContext(listenerCtx context.Context, loginCtx context.Context) context.Context {
+	md, _ := metadata.FromOutgoingContext(loginCtx)
+	return metadata.NewOutgoingContext(listenerCtx, md)
+}
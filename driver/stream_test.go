@@ -0,0 +1,24 @@
+package driver
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	backoff := &Backoff{Min: 100 * time.Millisecond, Max: time.Second}
+
+	expected := []time.Duration{100, 200, 400, 800, 1000, 1000}
+	for i, max := range expected {
+		max = max * time.Millisecond
+		delay := backoff.Next()
+		if delay < max/2 || delay > max {
+			t.Errorf("attempt %d: delay %s not within [%s, %s]", i, delay, max/2, max)
+		}
+	}
+
+	backoff.Reset()
+	if delay := backoff.Next(); delay > 100*time.Millisecond {
+		t.Errorf("delay %s after reset exceeds min", delay)
+	}
+}
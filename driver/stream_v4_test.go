@@ -0,0 +1,174 @@
+package driver
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/chirpstack/chirpstack/api/go/v4/api"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// fakeStreamServer sends one event on every stream and then kills it
+type fakeStreamServer struct {
+	api.UnimplementedInternalServiceServer
+	logins  int32
+	streams int32
+}
+
+func (s *fakeStreamServer) Login(ctx context.Context, req *api.LoginRequest) (*api.LoginResponse, error) {
+	atomic.AddInt32(&s.logins, 1)
+	return &api.LoginResponse{Jwt: "token"}, nil
+}
+
+func (s *fakeStreamServer) StreamDeviceEvents(req *api.StreamDeviceEventsRequest, stream api.InternalService_StreamDeviceEventsServer) error {
+	atomic.AddInt32(&s.streams, 1)
+	if md, _ := metadata.FromIncomingContext(stream.Context()); len(md.Get("authorization")) == 0 {
+		return status.Error(codes.Unauthenticated, "authorization missing")
+	}
+	if err := stream.Send(&api.LogItem{Description: "up", Body: "{}"}); err != nil {
+		return err
+	}
+	return status.Error(codes.Unavailable, "chirpstack restarting")
+}
+
+func TestSuperviseStreamReconnect(t *testing.T) {
+	server := &fakeStreamServer{}
+	listener := bufconn.Listen(1024 * 1024)
+	grpcServer := grpc.NewServer()
+	api.RegisterInternalServiceServer(grpcServer, server)
+	go grpcServer.Serve(listener) //nolint:errcheck
+	defer grpcServer.Stop()
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return listener.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial fake server failed: %v", err)
+	}
+	defer conn.Close()
+
+	client := api.NewInternalServiceClient(conn)
+	login := func() (context.Context, error) {
+		resp, err := client.Login(context.Background(), &api.LoginRequest{Email: "admin", Password: "admin"})
+		if err != nil {
+			return nil, err
+		}
+		return metadata.NewOutgoingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+resp.Jwt)), nil
+	}
+	ctx, err := login()
+	if err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+
+	var events int32
+	listenerCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	backoff := &Backoff{Min: time.Millisecond, Max: 10 * time.Millisecond}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		superviseStream(listenerCtx, logger.NewMockClient(), "test device", backoff, func(reconnect bool) (func() error, error) {
+			if reconnect {
+				var err error
+				if ctx, err = login(); err != nil {
+					return nil, err
+				}
+			}
+			stream, err := client.StreamDeviceEvents(streamContext(listenerCtx, ctx), &api.StreamDeviceEventsRequest{DevEui: "9d13b5893728d5f6"})
+			if err != nil {
+				return nil, err
+			}
+			return func() error {
+				if _, err := stream.Recv(); err != nil {
+					return err
+				}
+				if atomic.AddInt32(&events, 1) >= 3 {
+					cancel()
+				}
+				return nil
+			}, nil
+		})
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream was not reconnected")
+	}
+
+	if streams := atomic.LoadInt32(&server.streams); streams < 3 {
+		t.Errorf("expected at least 3 streams, got %d", streams)
+	}
+	// initial login plus one login per reconnect
+	if logins := atomic.LoadInt32(&server.logins); logins < 3 {
+		t.Errorf("expected at least 3 logins, got %d", logins)
+	}
+}
+
+// blockingStreamServer keeps every stream open until the client goes away
+type blockingStreamServer struct {
+	api.UnimplementedInternalServiceServer
+}
+
+func (s *blockingStreamServer) StreamDeviceEvents(req *api.StreamDeviceEventsRequest, stream api.InternalService_StreamDeviceEventsServer) error {
+	<-stream.Context().Done()
+	return stream.Context().Err()
+}
+
+func TestSuperviseStreamCancel(t *testing.T) {
+	listener := bufconn.Listen(1024 * 1024)
+	grpcServer := grpc.NewServer()
+	api.RegisterInternalServiceServer(grpcServer, &blockingStreamServer{})
+	go grpcServer.Serve(listener) //nolint:errcheck
+	defer grpcServer.Stop()
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return listener.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial fake server failed: %v", err)
+	}
+	defer conn.Close()
+
+	client := api.NewInternalServiceClient(conn)
+	listenerCtx, cancel := context.WithCancel(context.Background())
+	opened := make(chan struct{})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		superviseStream(listenerCtx, logger.NewMockClient(), "test device", &Backoff{Min: time.Millisecond, Max: time.Millisecond}, func(reconnect bool) (func() error, error) {
+			stream, err := client.StreamDeviceEvents(listenerCtx, &api.StreamDeviceEventsRequest{DevEui: "9d13b5893728d5f6"})
+			if err != nil {
+				return nil, err
+			}
+			if !reconnect {
+				close(opened)
+			}
+			return func() error {
+				_, err := stream.Recv()
+				return err
+			}, nil
+		})
+	}()
+
+	<-opened
+	// the stream is idle, cancel must end it without waiting for the next event
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream was not stopped by cancel")
+	}
+}
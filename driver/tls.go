@@ -0,0 +1,175 @@
+package driver
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/edgexfoundry/device-lora-go/config"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// TLSStore keeps the CA bundle and client certificate of the ChirpStack connection. The material is read from the
+// TLS secret, or else from the configured files, and is reloaded when the secret or the files change so that
+// new connections use it without a restart.
+type TLSStore struct {
+	config  config.ChirpStackConfig
+	secrets func() (map[string]string, error)
+
+	mutex       sync.RWMutex
+	roots       *x509.CertPool
+	certificate *tls.Certificate
+	modTimes    map[string]time.Time
+}
+
+// NewTLSStore loads the TLS material, secrets returns the TLS secret and is nil when no secret is configured
+func NewTLSStore(config config.ChirpStackConfig, secrets func() (map[string]string, error)) (*TLSStore, error) {
+	s := &TLSStore{
+		config:  config,
+		secrets: secrets,
+	}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reload reads the TLS material again
+func (s *TLSStore) Reload() error {
+	caPEM, certPEM, keyPEM, modTimes, err := s.read()
+	if err != nil {
+		return err
+	}
+
+	var roots *x509.CertPool
+	if len(caPEM) > 0 {
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(caPEM) {
+			return errors.New("no CA certificate found in the ChirpStack CA bundle")
+		}
+	}
+
+	var certificate *tls.Certificate
+	if len(certPEM) > 0 || len(keyPEM) > 0 {
+		pair, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return fmt.Errorf("invalid ChirpStack client certificate: %v", err)
+		}
+		certificate = &pair
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.roots = roots
+	s.certificate = certificate
+	s.modTimes = modTimes
+	return nil
+}
+
+// read returns the PEM material of the secret, or of the files together with their modification times
+func (s *TLSStore) read() (caPEM, certPEM, keyPEM []byte, modTimes map[string]time.Time, err error) {
+	if s.secrets != nil {
+		var secrets map[string]string
+		if secrets, err = s.secrets(); err != nil {
+			return
+		}
+		return []byte(secrets[SecretCACert]), []byte(secrets[SecretClientCert]), []byte(secrets[SecretClientKey]), nil, nil
+	}
+
+	modTimes = make(map[string]time.Time)
+	files := []string{s.config.CAFile, s.config.CertFile, s.config.KeyFile}
+	contents := make([][]byte, len(files))
+	for i, file := range files {
+		if len(file) == 0 {
+			continue
+		}
+		var info os.FileInfo
+		if info, err = os.Stat(file); err != nil {
+			return
+		}
+		if contents[i], err = os.ReadFile(file); err != nil {
+			return
+		}
+		modTimes[file] = info.ModTime()
+	}
+	return contents[0], contents[1], contents[2], modTimes, nil
+}
+
+// reloadChangedFiles reloads the material when one of the files has been modified
+func (s *TLSStore) reloadChangedFiles() error {
+	s.mutex.RLock()
+	modTimes := s.modTimes
+	s.mutex.RUnlock()
+
+	for file, modTime := range modTimes {
+		if info, err := os.Stat(file); err != nil || !info.ModTime().Equal(modTime) {
+			return s.Reload()
+		}
+	}
+	return nil
+}
+
+// Config returns the TLS config of the connection, the server certificate is verified against the current
+// CA bundle (the system roots when there is none) and the current client certificate is presented
+func (s *TLSStore) Config() *tls.Config {
+	serverName := s.config.ServerName
+	if len(serverName) == 0 {
+		serverName = s.config.Host
+		if host, _, err := net.SplitHostPort(s.config.Host); err == nil {
+			serverName = host
+		}
+	}
+
+	return &tls.Config{
+		ServerName: serverName,
+		MinVersion: tls.VersionTLS12,
+		// 证书由VerifyConnection按当前的CA校验，以便CA更新后无需重建连接配置
+		InsecureSkipVerify: true, //nolint:gosec
+		VerifyConnection: func(state tls.ConnectionState) error {
+			if err := s.reloadChangedFiles(); err != nil {
+				return err
+			}
+			if len(state.PeerCertificates) == 0 {
+				return errors.New("ChirpStack presented no certificate")
+			}
+
+			s.mutex.RLock()
+			roots := s.roots
+			s.mutex.RUnlock()
+
+			intermediates := x509.NewCertPool()
+			for _, certificate := range state.PeerCertificates[1:] {
+				intermediates.AddCert(certificate)
+			}
+			_, err := state.PeerCertificates[0].Verify(x509.VerifyOptions{
+				Roots:         roots,
+				DNSName:       serverName,
+				Intermediates: intermediates,
+			})
+			return err
+		},
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			s.mutex.RLock()
+			defer s.mutex.RUnlock()
+			if s.certificate == nil {
+				return &tls.Certificate{}, nil
+			}
+			return s.certificate, nil
+		},
+	}
+}
+
+// transportOption returns the dial option of the ChirpStack connection, plain text when TLS isn't enabled
+func transportOption(store *TLSStore) grpc.DialOption {
+	if store == nil {
+		return grpc.WithTransportCredentials(insecure.NewCredentials())
+	}
+	return grpc.WithTransportCredentials(credentials.NewTLS(store.Config()))
+}
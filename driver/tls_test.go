@@ -0,0 +1,179 @@
+package driver
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/edgexfoundry/device-lora-go/config"
+)
+
+type testCertificate struct {
+	certificate *x509.Certificate
+	key         *ecdsa.PrivateKey
+	certPEM     []byte
+	keyPEM      []byte
+}
+
+func newTestCertificate(t *testing.T, name string, parent *testCertificate, usage x509.ExtKeyUsage) *testCertificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign
+	} else {
+		template.ExtKeyUsage = []x509.ExtKeyUsage{usage}
+		signer, signerKey = parent.certificate, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certificate, _ := x509.ParseCertificate(der)
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	return &testCertificate{
+		certificate: certificate,
+		key:         key,
+		certPEM:     pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:      pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+func TestTLSStoreReload(t *testing.T) {
+	ca := newTestCertificate(t, "ca", nil, 0)
+	otherCA := newTestCertificate(t, "other", nil, 0)
+	server := newTestCertificate(t, "chirpstack.local", ca, x509.ExtKeyUsageServerAuth)
+	client := newTestCertificate(t, "device-lora", ca, x509.ExtKeyUsageClientAuth)
+
+	serverPair, _ := tls.X509KeyPair(server.certPEM, server.keyPEM)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.certificate)
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{serverPair},
+		ClientCAs:    clientCAs,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS12,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+
+	dir := t.TempDir()
+	chirpConfig := config.ChirpStackConfig{
+		Host:       listener.Addr().String(),
+		UseTLS:     true,
+		CAFile:     filepath.Join(dir, "ca.pem"),
+		CertFile:   filepath.Join(dir, "cert.pem"),
+		KeyFile:    filepath.Join(dir, "key.pem"),
+		ServerName: "chirpstack.local",
+	}
+	write := func(name string, data []byte, modTime time.Time) {
+		if err := os.WriteFile(name, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(name, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now := time.Now()
+	write(chirpConfig.CAFile, otherCA.certPEM, now.Add(-time.Minute))
+	write(chirpConfig.CertFile, client.certPEM, now.Add(-time.Minute))
+	write(chirpConfig.KeyFile, client.keyPEM, now.Add(-time.Minute))
+
+	store, err := NewTLSStore(chirpConfig, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handshake := func() error {
+		conn, err := tls.Dial("tcp", chirpConfig.Host, store.Config())
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		return conn.Handshake()
+	}
+
+	if err := handshake(); err == nil {
+		t.Fatal("expected the server certificate to be rejected by the other CA")
+	}
+
+	// the updated CA bundle is used by the next connection without a restart
+	write(chirpConfig.CAFile, ca.certPEM, now)
+	if err := handshake(); err != nil {
+		t.Fatalf("unexpected handshake error after reload: %v", err)
+	}
+
+	// the server name is verified
+	chirpConfig.ServerName = "other.local"
+	if store, err = NewTLSStore(chirpConfig, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := handshake(); err == nil {
+		t.Fatal("expected a server name mismatch")
+	}
+}
+
+func TestTLSStoreSecret(t *testing.T) {
+	ca := newTestCertificate(t, "ca", nil, 0)
+	client := newTestCertificate(t, "device-lora", ca, x509.ExtKeyUsageClientAuth)
+
+	secrets := map[string]string{SecretCACert: string(ca.certPEM)}
+	store, err := NewTLSStore(config.ChirpStackConfig{Host: "chirpstack.local:8080"}, func() (map[string]string, error) {
+		return secrets, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert, _ := store.Config().GetClientCertificate(nil); len(cert.Certificate) != 0 {
+		t.Errorf("expected no client certificate")
+	}
+	if serverName := store.Config().ServerName; serverName != "chirpstack.local" {
+		t.Errorf("expected server name from host, got %s", serverName)
+	}
+
+	secrets[SecretClientCert], secrets[SecretClientKey] = string(client.certPEM), string(client.keyPEM)
+	if err := store.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if cert, _ := store.Config().GetClientCertificate(nil); len(cert.Certificate) == 0 {
+		t.Errorf("expected the client certificate of the secret")
+	}
+
+	secrets[SecretClientKey] = "invalid"
+	if err := store.Reload(); err == nil {
+		t.Errorf("expected an invalid key error")
+	}
+}
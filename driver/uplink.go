@@ -0,0 +1,163 @@
+package driver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	sdkModels "github.com/edgexfoundry/device-sdk-go/v3/pkg/models"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/common"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/models"
+)
+
+// RadioMetadata is the link quality of an uplink as reported by the gateway with the best RSSI
+type RadioMetadata struct {
+	GatewayId       string
+	Rssi            int32
+	Snr             float64
+	Frequency       uint32
+	DataRate        uint32
+	SpreadingFactor uint32
+	FCnt            uint32
+	FPort           uint32
+}
+
+// values returns the metadata keyed by the device resource names which receive them, the spreading factor is
+// left out when it is unknown (v3)
+func (m *RadioMetadata) values() map[string]interface{} {
+	values := map[string]interface{}{
+		GatewayIdResource: m.GatewayId,
+		RssiResource:      m.Rssi,
+		SnrResource:       m.Snr,
+		FrequencyResource: m.Frequency,
+		DataRateResource:  m.DataRate,
+		FCntResource:      m.FCnt,
+		FPortResource:     m.FPort,
+	}
+	if m.SpreadingFactor > 0 {
+		values[SpreadingFactorResource] = m.SpreadingFactor
+	}
+	return values
+}
+
+// uplinkResults maps the codec output of an uplink onto the device resources, the codec resource receives the
+// whole object and every other readable resource receives the field at its jsonPath (default: resource name).
+// The radio metadata is published as readings or reading tags when the codec resource enables it.
+// The readings take origin, the receive time reported by ChirpStack, or the local clock when it is zero.
+func (driver *LoraDriver) uplinkResults(deviceName string, object map[string]interface{}, metadata *RadioMetadata, origin time.Time) (sourceName string, results []*sdkModels.CommandValue, err error) {
+	var device models.Device
+	if device, err = driver.sdk.GetDeviceByName(deviceName); err != nil {
+		return
+	}
+
+	var profile models.DeviceProfile
+	if profile, err = driver.sdk.GetProfileByName(device.ProfileName); err != nil {
+		return
+	}
+
+	codecResource, ok := findCodecResource(profile)
+	if !ok {
+		return "", nil, fmt.Errorf("device %s has no codec resource", deviceName)
+	}
+	sourceName = codecResource.Name
+
+	var metadataValues map[string]interface{}
+	mode := fmt.Sprintf("%v", codecResource.Properties.Optional[RADIOMETADATA])
+	if metadata != nil && (mode == RadioMetadataReadings || mode == RadioMetadataTags) {
+		metadataValues = metadata.values()
+	}
+
+	if result, err := driver.NewResult(codecResource, object); err == nil {
+		results = append(results, result)
+	}
+
+	for _, resource := range profile.DeviceResources {
+		if !isUplinkResource(resource) {
+			continue
+		}
+
+		var value interface{}
+		if metadataValue, ok := metadataValues[resource.Name]; ok && mode == RadioMetadataReadings {
+			value = metadataValue
+		} else {
+			path := resource.Name
+			if value, ok := resource.Properties.Optional[JSONPATH]; ok {
+				path = fmt.Sprintf("%v", value)
+			}
+
+			if value, ok = lookupJSONPath(object, path); !ok {
+				continue
+			}
+		}
+
+		result, err := driver.NewResult(resource, value)
+		if err != nil {
+			driver.logger.Debugf("[listener] device %s resource %s ignored: %v", deviceName, resource.Name, err)
+			continue
+		}
+		results = append(results, result)
+	}
+
+	if !origin.IsZero() {
+		for _, result := range results {
+			result.Origin = origin.UnixNano()
+		}
+	}
+
+	if mode == RadioMetadataTags && len(metadataValues) > 0 {
+		for _, result := range results {
+			result.Tags = make(map[string]string, len(metadataValues))
+			for key, value := range metadataValues {
+				result.Tags[key] = fmt.Sprintf("%v", value)
+			}
+		}
+	}
+
+	return sourceName, results, nil
+}
+
+// isUplinkResource reports whether a resource is filled from the uplink codec output
+func isUplinkResource(resource models.DeviceResource) bool {
+	if _, ok := resource.Properties.Optional[CODEC]; ok {
+		return false
+	}
+
+	switch resource.Name {
+	case DownlinkStatusResource, QueueResource, QueueCountResource, FlushQueueResource:
+		return false
+	}
+
+	switch resource.Properties.ReadWrite {
+	case common.ReadWrite_R, common.ReadWrite_RW, common.ReadWrite_WR:
+		return true
+	}
+	return false
+}
+
+// lookupJSONPath returns the value at a dot separated path such as "sensor.temperature" or "values.0"
+func lookupJSONPath(object interface{}, path string) (interface{}, bool) {
+	value := object
+	for _, key := range strings.Split(path, ".") {
+		switch node := value.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if value, ok = node[key]; !ok {
+				return nil, false
+			}
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil, false
+			}
+			value = node[index]
+		default:
+			return nil, false
+		}
+	}
+
+	if value == nil {
+		return nil, false
+	}
+	return value, true
+}
@@ -0,0 +1,132 @@
+package driver
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/clients/logger"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/common"
+	"github.com/edgexfoundry/go-mod-core-contracts/v3/models"
+)
+
+func TestLookupJSONPath(t *testing.T) {
+	var object map[string]interface{}
+	if err := json.Unmarshal([]byte(`{"temperature":21.5,"sensor":{"humidity":40,"values":[1,2]},"empty":null}`), &object); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path     string
+		expected interface{}
+		found    bool
+	}{
+		{"temperature", 21.5, true},
+		{"sensor.humidity", float64(40), true},
+		{"sensor.values.1", float64(2), true},
+		{"sensor.values.2", nil, false},
+		{"sensor.values.x", nil, false},
+		{"temperature.value", nil, false},
+		{"co2", nil, false},
+		{"empty", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			value, found := lookupJSONPath(object, tt.path)
+			if found != tt.found {
+				t.Fatalf("expected found %v, got %v", tt.found, found)
+			}
+			if found && value != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, value)
+			}
+		})
+	}
+}
+
+func TestNewResultCastsUplinkValue(t *testing.T) {
+	driver := &LoraDriver{}
+
+	tests := []struct {
+		name      string
+		valueType string
+		reading   interface{}
+		expected  interface{}
+		err       bool
+	}{
+		{"float32", common.ValueTypeFloat32, 21.5, float32(21.5), false},
+		{"uint16", common.ValueTypeUint16, float64(300), uint16(300), false},
+		{"int8", common.ValueTypeInt8, float64(-3), int8(-3), false},
+		{"bool", common.ValueTypeBool, true, true, false},
+		{"string", common.ValueTypeString, "on", "on", false},
+		{"string from number", common.ValueTypeString, float64(5), "5", false},
+		{"invalid number", common.ValueTypeFloat32, "warm", nil, true},
+		{"binary", common.ValueTypeBinary, "AQI=", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := driver.NewResult(newResource(tt.valueType, nil), tt.reading)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected error, got %v", result.Value)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result.Value != tt.expected {
+				t.Errorf("expected %v (%T), got %v (%T)", tt.expected, tt.expected, result.Value, result.Value)
+			}
+		})
+	}
+}
+
+func TestUplinkResultsTags(t *testing.T) {
+	profile := models.DeviceProfile{Name: "Lora-Device-CC10LD", DeviceResources: []models.DeviceResource{
+		{Name: "json", Properties: models.ResourceProperties{ValueType: common.ValueTypeObject, ReadWrite: common.ReadWrite_R,
+			Optional: map[string]any{CODEC: "v1", RADIOMETADATA: RadioMetadataTags}}},
+		{Name: "temperature", Properties: models.ResourceProperties{ValueType: common.ValueTypeFloat64, ReadWrite: common.ReadWrite_R}},
+	}}
+	driver := &LoraDriver{
+		sdk: &fakeSDK{
+			devices:  map[string]models.Device{"sensor-1": {Name: "sensor-1", ProfileName: profile.Name}},
+			profiles: map[string]models.DeviceProfile{profile.Name: profile},
+		},
+		logger: logger.NewMockClient(),
+	}
+
+	tests := []struct {
+		name     string
+		metadata *RadioMetadata
+		tags     map[string]string
+	}{
+		{"metadata", &RadioMetadata{GatewayId: "a", Rssi: -80}, map[string]string{
+			GatewayIdResource: "a", RssiResource: "-80", SnrResource: "0", FrequencyResource: "0",
+			DataRateResource: "0", FCntResource: "0", FPortResource: "0"}},
+		{"no metadata", nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, results, err := driver.uplinkResults("sensor-1", map[string]interface{}{"temperature": 21.5}, tt.metadata, time.Time{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results) != 2 {
+				t.Fatalf("expected 2 readings, got %d", len(results))
+			}
+			for _, result := range results {
+				if len(result.Tags) != len(tt.tags) {
+					t.Errorf("expected tags %v on %s, got %v", tt.tags, result.DeviceResourceName, result.Tags)
+				}
+				for key, value := range tt.tags {
+					if result.Tags[key] != value {
+						t.Errorf("expected tag %s %s on %s, got %v", key, value, result.DeviceResourceName, result.Tags)
+					}
+				}
+			}
+		})
+	}
+}
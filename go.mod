@@ -6,6 +6,7 @@ require (
 	github.com/edgexfoundry/device-sdk-go/v3 v3.1.0-dev.33
 	github.com/edgexfoundry/go-mod-core-contracts/v3 v3.1.0-dev.16
 	github.com/labstack/echo/v4 v4.11.1
+	github.com/rcrowley/go-metrics v0.0.0-20201227073835-cf1acfcdf475
 	github.com/spf13/cast v1.5.1
 )
 
@@ -19,7 +20,7 @@ require (
 	github.com/cenkalti/backoff v2.2.1+incompatible // indirect
 	github.com/chirpstack/chirpstack/api/go/v4 v4.5.1
 	github.com/eclipse/paho.mqtt.golang v1.4.3 // indirect
-	github.com/edgexfoundry/go-mod-bootstrap/v3 v3.1.0-dev.46 // indirect
+	github.com/edgexfoundry/go-mod-bootstrap/v3 v3.1.0-dev.46
 	github.com/edgexfoundry/go-mod-configuration/v3 v3.1.0-dev.7 // indirect
 	github.com/edgexfoundry/go-mod-messaging/v3 v3.1.0-dev.25 // indirect
 	github.com/edgexfoundry/go-mod-registry/v3 v3.1.0-dev.7 // indirect
@@ -60,7 +61,6 @@ require (
 	github.com/nats-io/nats.go v1.30.2 // indirect
 	github.com/nats-io/nkeys v0.4.5 // indirect
 	github.com/nats-io/nuid v1.0.1 // indirect
-	github.com/rcrowley/go-metrics v0.0.0-20201227073835-cf1acfcdf475 // indirect
 	github.com/satori/go.uuid v1.2.0
 	github.com/spiffe/go-spiffe/v2 v2.1.6 // indirect
 	github.com/valyala/bytebufferpool v1.0.0 // indirect
@@ -78,6 +78,6 @@ require (
 	golang.org/x/tools v0.6.0 // indirect
 	google.golang.org/genproto v0.0.0-20230223222841-637eb2293923 // indirect
 	google.golang.org/grpc v1.53.0
-	google.golang.org/protobuf v1.31.0 // indirect
+	google.golang.org/protobuf v1.31.0
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
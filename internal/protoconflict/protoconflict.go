@@ -0,0 +1,42 @@
+// Package protoconflict allows the ChirpStack v3 and v4 APIs in one binary. Both register protobuf files and
+// messages with the same names, which panics at startup by default: the packages
+// github.com/brocaar/chirpstack-api/go/v3/{as/external/api,common,gw} and
+// github.com/chirpstack/chirpstack/api/go/v4/{api,common,gw} register the files common/common.proto and
+// gw/gw.proto and the messages and enums common.*, gw.* and api.* (e.g. api.DeviceProfile) twice.
+//
+// Ignoring the conflict only keeps the second registration out of the global registry. The generated code of each
+// version marshals its messages and serves its gRPC methods with its own descriptors, so both versions work. Only
+// the lookups by name in the global registry (protoregistry, Any, grpc reflection) get the version registered
+// first, the service doesn't use them.
+//
+// The policy is read from the environment when a conflict is registered, so this package has to be initialized
+// before the API packages. Go initializes the packages sorted by import path once their imports are initialized,
+// this package only imports os and sorts before google.golang.org/protobuf which the API packages import.
+// cmd/main.go imports it first to make the requirement visible, cmd/main_test.go runs the built binary without
+// the environment variable to catch a changed order.
+//
+// The policy is only relaxed while the API packages register: the driver package, initialized after them,
+// calls Restore so later conflicts panic again.
+package protoconflict
+
+import "os"
+
+const conflictPolicyEnv = "GOLANG_PROTOBUF_REGISTRATION_CONFLICT"
+
+// overridden is whether init set the policy, the policy of the environment is kept otherwise
+var overridden bool
+
+func init() {
+	// 已设置的策略优先
+	if len(os.Getenv(conflictPolicyEnv)) == 0 {
+		overridden = os.Setenv(conflictPolicyEnv, "ignore") == nil
+	}
+}
+
+// Restore removes the policy set by init, it is called once the ChirpStack API packages have been initialized
+func Restore() {
+	if overridden {
+		_ = os.Unsetenv(conflictPolicyEnv)
+		overridden = false
+	}
+}
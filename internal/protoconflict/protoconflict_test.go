@@ -0,0 +1,63 @@
+package protoconflict_test
+
+import (
+	"testing"
+
+	v3api "github.com/brocaar/chirpstack-api/go/v3/as/external/api"
+	v4api "github.com/chirpstack/chirpstack/api/go/v4/api"
+	_ "github.com/edgexfoundry/device-lora-go/internal/protoconflict"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+func TestDescriptors(t *testing.T) {
+	tests := []struct {
+		name    string
+		message proto.Message
+		empty   proto.Message
+		file    string
+		field   protoreflect.Name
+	}{
+		{"v3", &v3api.DeviceProfile{Name: "Lora-Device-CC10LD", PayloadDecoderScript: "v3"}, &v3api.DeviceProfile{},
+			"as/external/api/profiles.proto", "payload_decoder_script"},
+		{"v4", &v4api.DeviceProfile{Name: "Lora-Device-CC10LD", PayloadCodecScript: "v4"}, &v4api.DeviceProfile{},
+			"api/device_profile.proto", "payload_codec_script"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// 两个版本的消息同名，但各自使用生成代码中的描述符
+			descriptor := proto.MessageV2(test.message).ProtoReflect().Descriptor()
+			if descriptor.FullName() != "api.DeviceProfile" {
+				t.Errorf("expected api.DeviceProfile, got %s", descriptor.FullName())
+			}
+			if path := descriptor.ParentFile().Path(); path != test.file {
+				t.Errorf("expected file %s, got %s", test.file, path)
+			}
+			if descriptor.Fields().ByName(test.field) == nil {
+				t.Errorf("expected field %s", test.field)
+			}
+
+			data, err := proto.Marshal(test.message)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = proto.Unmarshal(data, test.empty); err != nil {
+				t.Fatal(err)
+			}
+			if !proto.Equal(test.message, test.empty) {
+				t.Errorf("expected %v, got %v", test.message, test.empty)
+			}
+		})
+	}
+
+	// 按名称查找全局注册表时只能得到先初始化的版本的消息（取决于包的初始化顺序），设备服务不按名称查找消息
+	messageType, err := protoregistry.GlobalTypes.FindMessageByName("api.DeviceProfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path := messageType.Descriptor().ParentFile().Path(); path != tests[0].file && path != tests[1].file {
+		t.Errorf("expected the v3 or v4 message registered, got %s", path)
+	}
+}
@@ -1,6 +1,3 @@
-//go:build chirpstack3
-// +build chirpstack3
-
 package v3
 
 import (
@@ -9,188 +6,343 @@ import (
 	"fmt"
 
 	"github.com/brocaar/chirpstack-api/go/v3/as/external/api"
-	"github.com/edgexfoundry/device-lora-go/config"
-	"github.com/golang/protobuf/ptypes/duration"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 var (
 	Limit64 int64 = 100
 )
 
-func Init(conn *grpc.ClientConn, config config.ChirpStackConfig) (netId, orgId, appId int64, err error) {
-	var ctx context.Context
-	// 登录chirpstack
-	if ctx, err = Login(conn, config.Username, config.Password); err != nil {
-		return
-	}
+// Scope selects the network server, organization and application of the devices. The configured ids are verified,
+// otherwise the ones of the name, or the only one when the name is empty, are used. AutoCreate creates the missing
+// ones with the names, the network server needs its address and the application the only service profile of the
+// organization.
+type Scope struct {
+	NetworkServerId      int64
+	NetworkServerName    string
+	NetworkServerAddress string
+	OrganizationId       int64
+	OrganizationName     string
+	ApplicationId        int64
+	ApplicationName      string
+	AutoCreate           bool
+}
+
+func Init(conn *grpc.ClientConn, ctx context.Context, scope Scope) (netId, orgId, appId int64, err error) {
 	// 获取netWorkServer
-	if netId, err = initNetWorkServer(conn, ctx); err != nil {
+	if netId, err = initNetWorkServer(conn, ctx, scope); err != nil {
+		err = fmt.Errorf("unable to get network server: %v", err)
 		return
 	}
 	// 获取organization
-	if orgId, err = initOrganization(conn, ctx); err != nil {
+	if orgId, err = initOrganization(conn, ctx, scope); err != nil {
+		err = fmt.Errorf("unable to get organization: %v", err)
 		return
 	}
 	// 获取application
-	if appId, err = initApplication(conn, ctx, orgId); err != nil {
+	if appId, err = initApplication(conn, ctx, netId, orgId, scope); err != nil {
+		err = fmt.Errorf("unable to get application: %v", err)
 		return
 	}
 
 	return
 }
 
-func Login(conn *grpc.ClientConn, username, password string) (ctx context.Context, err error) {
+func Login(conn *grpc.ClientConn, username, password string) (jwt string, err error) {
 	client := api.NewInternalServiceClient(conn)
 	var resp *api.LoginResponse
 	if resp, err = client.Login(context.Background(), &api.LoginRequest{
 		Email:    username,
 		Password: password,
 	}); err != nil {
-		return nil, err
+		return "", err
 	}
 
-	fmt.Println("jwt", resp.Jwt)
-	ctx = metadata.NewOutgoingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+resp.Jwt))
-	return
+	return resp.Jwt, nil
 }
 
-func initOrganization(conn *grpc.ClientConn, ctx context.Context) (organizationId int64, err error) {
-	// 返回值为 ApplicationServiceClient
+func initOrganization(conn *grpc.ClientConn, ctx context.Context, scope Scope) (organizationId int64, err error) {
 	client := api.NewOrganizationServiceClient(conn)
-	var resp *api.ListOrganizationResponse
-	if resp, err = client.List(ctx, &api.ListOrganizationRequest{
-		Limit: Limit64,
-	}); err != nil {
-		return -1, err
+	// 配置的organization需存在
+	if scope.OrganizationId > 0 {
+		if _, err = client.Get(ctx, &api.GetOrganizationRequest{
+			Id: scope.OrganizationId,
+		}); err != nil {
+			return -1, fmt.Errorf("organization %d: %v", scope.OrganizationId, err)
+		}
+		return scope.OrganizationId, nil
 	}
 
-	if len(resp.Result) > 0 {
-		return resp.Result[0].Id, nil
-	} else {
-		var resp *api.CreateOrganizationResponse
-		if resp, err = client.Create(ctx, &api.CreateOrganizationRequest{
-			Organization: &api.Organization{
-				Name: "Starblaze",
-			},
-		}); err == nil {
-			return resp.Id, nil
+	var organizations []*api.OrganizationListItem
+	if organizations, err = listAll(func(offset int64) ([]*api.OrganizationListItem, int64, error) {
+		resp, err := client.List(ctx, &api.ListOrganizationRequest{
+			Limit:  Limit64,
+			Offset: offset,
+			Search: scope.OrganizationName,
+		})
+		if err != nil {
+			return nil, 0, err
 		}
+		return resp.Result, resp.TotalCount, nil
+	}); err != nil {
+		return -1, err
+	}
+	var found bool
+	if organizationId, found, err = findItem(organizations, scope.OrganizationName, func(organization *api.OrganizationListItem) (string, int64) {
+		return organization.Name, organization.Id
+	}); err != nil || found {
+		return
 	}
 
-	return -1, errors.New("init Organization fail")
+	// 不存在时按配置创建
+	if !scope.AutoCreate || len(scope.OrganizationName) == 0 {
+		return -1, fmt.Errorf("organization %s not found, ChirpStack.AutoCreate and ChirpStack.OrganizationName create it", scope.OrganizationName)
+	}
+	var resp *api.CreateOrganizationResponse
+	if resp, err = client.Create(ctx, &api.CreateOrganizationRequest{
+		Organization: &api.Organization{
+			Name:            scope.OrganizationName,
+			DisplayName:     scope.OrganizationName,
+			CanHaveGateways: true,
+		},
+	}); err != nil {
+		return -1, err
+	}
+	return resp.Id, nil
 }
 
-func initNetWorkServer(conn *grpc.ClientConn, ctx context.Context) (netWorkServerId int64, err error) {
+func initNetWorkServer(conn *grpc.ClientConn, ctx context.Context, scope Scope) (netWorkServerId int64, err error) {
 	client := api.NewNetworkServerServiceClient(conn)
-	var resp *api.ListNetworkServerResponse
-	if resp, err = client.List(ctx, &api.ListNetworkServerRequest{
-		Limit: Limit64,
-	}); err != nil {
-		return -1, err
+	// 配置的netWorkServer需存在
+	if scope.NetworkServerId > 0 {
+		if _, err = client.Get(ctx, &api.GetNetworkServerRequest{
+			Id: scope.NetworkServerId,
+		}); err != nil {
+			return -1, fmt.Errorf("network server %d: %v", scope.NetworkServerId, err)
+		}
+		return scope.NetworkServerId, nil
 	}
 
-	if len(resp.Result) > 0 {
-		return resp.Result[0].Id, nil
-	} else {
-		var resp *api.CreateNetworkServerResponse
-		if resp, err = client.Create(ctx, &api.CreateNetworkServerRequest{
-			NetworkServer: &api.NetworkServer{
-				Name: "Starblaze",
-			},
-		}); err == nil {
-			return resp.Id, nil
+	var networkServers []*api.NetworkServerListItem
+	if networkServers, err = listAll(func(offset int64) ([]*api.NetworkServerListItem, int64, error) {
+		resp, err := client.List(ctx, &api.ListNetworkServerRequest{
+			Limit:  Limit64,
+			Offset: offset,
+		})
+		if err != nil {
+			return nil, 0, err
 		}
+		return resp.Result, resp.TotalCount, nil
+	}); err != nil {
+		return -1, err
+	}
+	var found bool
+	if netWorkServerId, found, err = findItem(networkServers, scope.NetworkServerName, func(networkServer *api.NetworkServerListItem) (string, int64) {
+		return networkServer.Name, networkServer.Id
+	}); err != nil || found {
+		return
 	}
 
-	return -1, errors.New("init networkserver fail")
+	// 不存在时按配置创建
+	if !scope.AutoCreate || len(scope.NetworkServerName) == 0 || len(scope.NetworkServerAddress) == 0 {
+		return -1, fmt.Errorf("network server %s not found, ChirpStack.AutoCreate, ChirpStack.NetworkServerName and ChirpStack.NetworkServerAddress create it", scope.NetworkServerName)
+	}
+	var resp *api.CreateNetworkServerResponse
+	if resp, err = client.Create(ctx, &api.CreateNetworkServerRequest{
+		NetworkServer: &api.NetworkServer{
+			Name:   scope.NetworkServerName,
+			Server: scope.NetworkServerAddress,
+		},
+	}); err != nil {
+		return -1, err
+	}
+	return resp.Id, nil
 }
 
-func initApplication(conn *grpc.ClientConn, ctx context.Context, organizationId int64) (id int64, err error) {
+func initApplication(conn *grpc.ClientConn, ctx context.Context, netWorkServerId int64, organizationId int64, scope Scope) (id int64, err error) {
 	client := api.NewApplicationServiceClient(conn)
+	// 配置的application需存在且属于该organization
+	if scope.ApplicationId > 0 {
+		var resp *api.GetApplicationResponse
+		if resp, err = client.Get(ctx, &api.GetApplicationRequest{
+			Id: scope.ApplicationId,
+		}); err != nil {
+			return -1, fmt.Errorf("application %d: %v", scope.ApplicationId, err)
+		}
+		if resp.Application.OrganizationId != organizationId {
+			return -1, fmt.Errorf("application %d doesn't belong to organization %d", scope.ApplicationId, organizationId)
+		}
+		return scope.ApplicationId, nil
+	}
 
-	var resp *api.ListApplicationResponse
-	if resp, err = client.List(ctx, &api.ListApplicationRequest{
-		Limit:          Limit64,
-		OrganizationId: organizationId,
+	var applications []*api.ApplicationListItem
+	if applications, err = listAll(func(offset int64) ([]*api.ApplicationListItem, int64, error) {
+		resp, err := client.List(ctx, &api.ListApplicationRequest{
+			Limit:          Limit64,
+			Offset:         offset,
+			OrganizationId: organizationId,
+			Search:         scope.ApplicationName,
+		})
+		if err != nil {
+			return nil, 0, err
+		}
+		return resp.Result, resp.TotalCount, nil
 	}); err != nil {
 		return -1, err
 	}
+	var found bool
+	if id, found, err = findItem(applications, scope.ApplicationName, func(application *api.ApplicationListItem) (string, int64) {
+		return application.Name, application.Id
+	}); err != nil || found {
+		return
+	}
 
-	if len(resp.Result) > 0 {
-		return resp.Result[0].Id, nil
-	} else {
-		var resp *api.CreateApplicationResponse
-		if resp, err = client.Create(ctx, &api.CreateApplicationRequest{
-			Application: &api.Application{
-				Name:           "Starblaze App",
-				OrganizationId: organizationId,
-			},
-		}); err == nil {
-			return resp.Id, nil
+	// 不存在时按配置创建，使用organization唯一的service profile
+	if !scope.AutoCreate || len(scope.ApplicationName) == 0 {
+		return -1, fmt.Errorf("application %s not found, ChirpStack.AutoCreate and ChirpStack.ApplicationName create it", scope.ApplicationName)
+	}
+	serviceProfileClient := api.NewServiceProfileServiceClient(conn)
+	var serviceProfiles []*api.ServiceProfileListItem
+	if serviceProfiles, err = listAll(func(offset int64) ([]*api.ServiceProfileListItem, int64, error) {
+		resp, err := serviceProfileClient.List(ctx, &api.ListServiceProfileRequest{
+			Limit:           Limit64,
+			Offset:          offset,
+			OrganizationId:  organizationId,
+			NetworkServerId: netWorkServerId,
+		})
+		if err != nil {
+			return nil, 0, err
 		}
+		return resp.Result, resp.TotalCount, nil
+	}); err != nil {
+		return -1, err
 	}
-	return -1, err
+	var serviceProfileId string
+	if serviceProfileId, found, err = findItem(serviceProfiles, "", func(serviceProfile *api.ServiceProfileListItem) (string, string) {
+		return serviceProfile.Name, serviceProfile.Id
+	}); err != nil {
+		return -1, fmt.Errorf("service profile: %v", err)
+	} else if !found {
+		return -1, errors.New("the organization has no service profile to create the application")
+	}
+
+	var resp *api.CreateApplicationResponse
+	if resp, err = client.Create(ctx, &api.CreateApplicationRequest{
+		Application: &api.Application{
+			Name:             scope.ApplicationName,
+			Description:      scope.ApplicationName,
+			OrganizationId:   organizationId,
+			ServiceProfileId: serviceProfileId,
+		},
+	}); err != nil {
+		return -1, err
+	}
+	return resp.Id, nil
 }
 
-func CreateProfile(conn *grpc.ClientConn, ctx context.Context, netId int64, orgId int64, appId int64, name string, codec string) (id string, err error) {
-	client := api.NewDeviceProfileServiceClient(conn)
-	var resp *api.ListDeviceProfileResponse
-	if resp, err = client.List(ctx, &api.ListDeviceProfileRequest{
-		Limit:          Limit64,
-		OrganizationId: orgId,
-		ApplicationId:  appId,
-	}); err == nil && resp.Result != nil {
-		fmt.Println("profiles", resp.Result)
-		for _, profile := range resp.Result {
-			if profile.Name == name {
-				return profile.Id, nil
-			}
-		}
+func CreateProfile(conn *grpc.ClientConn, ctx context.Context, netId int64, orgId int64, appId int64, profile *api.DeviceProfile) (id string, err error) {
+	//返回已存在的profile
+	if id, err = FindProfile(conn, ctx, orgId, appId, profile.Name); err != nil || len(id) > 0 {
+		return
 	}
 
 	//创建profile
-	var resp1 *api.CreateDeviceProfileResponse
-	if resp1, err = client.Create(ctx, &api.CreateDeviceProfileRequest{
-		DeviceProfile: &api.DeviceProfile{
-			NetworkServerId:      netId,
-			OrganizationId:       orgId,
-			Name:                 name,
-			MacVersion:           "1.0.2",
-			RegParamsRevision:    "A",
-			MaxEirp:              20,
-			PayloadCodec:         "CUSTOM_JS",
-			PayloadDecoderScript: codec,
-			UplinkInterval: &duration.Duration{
-				Seconds: 600,
-			},
-			AdrAlgorithmId: "default",
-		},
+	client := api.NewDeviceProfileServiceClient(conn)
+	profile.NetworkServerId = netId
+	profile.OrganizationId = orgId
+	var resp *api.CreateDeviceProfileResponse
+	if resp, err = client.Create(ctx, &api.CreateDeviceProfileRequest{
+		DeviceProfile: profile,
 	}); err == nil {
-		return resp1.Id, nil
+		return resp.Id, nil
 	}
 
 	return "", err
 }
 
+// DeleteProfile deletes the device profile of the name, nothing is done when it doesn't exist
 func DeleteProfile(conn *grpc.ClientConn, ctx context.Context, orgId int64, appId int64, name string) (err error) {
+	var id string
+	if id, err = FindProfile(conn, ctx, orgId, appId, name); err != nil || len(id) == 0 {
+		return
+	}
+
 	client := api.NewDeviceProfileServiceClient(conn)
-	var resp *api.ListDeviceProfileResponse
-	if resp, err = client.List(ctx, &api.ListDeviceProfileRequest{
-		Limit:          Limit64,
-		OrganizationId: orgId,
-		ApplicationId:  appId,
-	}); err == nil && resp.Result != nil {
-		for _, profile := range resp.Result {
-			if profile.Name == name {
-				if _, err = client.Delete(ctx, &api.DeleteDeviceProfileRequest{
-					Id: profile.Id,
-				}); err == nil {
-					fmt.Println("profile delete success")
-				}
-			}
+	if _, err = client.Delete(ctx, &api.DeleteDeviceProfileRequest{
+		Id: id,
+	}); err != nil {
+		fmt.Println("profile delete fail", err)
+		return
+	}
+	fmt.Println("profile delete success")
+	return
+}
+
+// FindProfile returns the id of the device profile of the name, it is empty when the profile doesn't exist
+func FindProfile(conn *grpc.ClientConn, ctx context.Context, orgId int64, appId int64, name string) (id string, err error) {
+	client := api.NewDeviceProfileServiceClient(conn)
+	if err = eachItem(func(offset int64) ([]*api.DeviceProfileListItem, int64, error) {
+		resp, err := client.List(ctx, &api.ListDeviceProfileRequest{
+			Limit:          Limit64,
+			Offset:         offset,
+			OrganizationId: orgId,
+			ApplicationId:  appId,
+		})
+		if err != nil {
+			return nil, 0, err
 		}
+		return resp.Result, resp.TotalCount, nil
+	}, func(profile *api.DeviceProfileListItem) bool {
+		if profile.Name == name {
+			id = profile.Id
+			return false
+		}
+		return true
+	}); err != nil {
+		fmt.Println("profile list fail", err)
+	}
+	return
+}
+
+func UpdateProfile(conn *grpc.ClientConn, ctx context.Context, profile *api.DeviceProfile) (err error) {
+	client := api.NewDeviceProfileServiceClient(conn)
+	if _, err = client.Update(ctx, &api.UpdateDeviceProfileRequest{
+		DeviceProfile: profile,
+	}); err != nil {
+		fmt.Println("profile update fail", err)
+	}
+	return
+}
+
+func GetProfile(conn *grpc.ClientConn, ctx context.Context, id string) (profile *api.DeviceProfile, err error) {
+	client := api.NewDeviceProfileServiceClient(conn)
+	var resp *api.GetDeviceProfileResponse
+	if resp, err = client.Get(ctx, &api.GetDeviceProfileRequest{
+		Id: id,
+	}); err != nil {
+		fmt.Println("profile get fail", err)
+		return
+	}
+	return resp.DeviceProfile, nil
+}
+
+// ListGateways returns all the gateways of the organization
+func ListGateways(conn *grpc.ClientConn, ctx context.Context, orgId int64) (gateways []*api.GatewayListItem, err error) {
+	client := api.NewGatewayServiceClient(conn)
+	if gateways, err = listAll(func(offset int64) ([]*api.GatewayListItem, int64, error) {
+		resp, err := client.List(ctx, &api.ListGatewayRequest{
+			Limit:          int32(Limit64),
+			Offset:         int32(offset),
+			OrganizationId: orgId,
+		})
+		if err != nil {
+			return nil, 0, err
+		}
+		return resp.Result, resp.TotalCount, nil
+	}); err != nil {
+		fmt.Println("gateway list fail", err)
 	}
 	return
 }
@@ -257,7 +409,7 @@ func DeleteGateway(conn *grpc.ClientConn, ctx context.Context, gateWayId string)
 	return
 }
 
-func CreateDevice(conn *grpc.ClientConn, ctx context.Context, DevEUI string, name string, deviceProfileId string, applicationId int64) (err error) {
+func CreateDevice(conn *grpc.ClientConn, ctx context.Context, DevEUI string, name string, deviceProfileId string, disabled bool, applicationId int64) (err error) {
 	client := api.NewDeviceServiceClient(conn)
 	if _, err = client.Create(ctx, &api.CreateDeviceRequest{
 		Device: &api.Device{
@@ -265,7 +417,7 @@ func CreateDevice(conn *grpc.ClientConn, ctx context.Context, DevEUI string, nam
 			Name:            name,
 			ApplicationId:   applicationId,
 			DeviceProfileId: deviceProfileId,
-			IsDisabled:      false,
+			IsDisabled:      disabled,
 			SkipFCntCheck:   true,
 		},
 	}); err == nil {
@@ -278,49 +430,185 @@ func CreateDevice(conn *grpc.ClientConn, ctx context.Context, DevEUI string, nam
 	return
 }
 
-func ActivateDevice(conn *grpc.ClientConn, ctx context.Context, DevEUI string, key string) (err error) {
+// DeviceExists returns whether the device is registered in ChirpStack
+func DeviceExists(conn *grpc.ClientConn, ctx context.Context, DevEUI string) (exists bool, err error) {
 	client := api.NewDeviceServiceClient(conn)
-	var resp *api.GetRandomDevAddrResponse
-	if resp, err = client.GetRandomDevAddr(ctx, &api.GetRandomDevAddrRequest{
+	if _, err = client.Get(ctx, &api.GetDeviceRequest{
 		DevEui: DevEUI,
 	}); err != nil {
-		fmt.Println("dev get addr fail", err)
-	} else {
-		fmt.Println("dev get addr", resp.DevAddr)
-		if _, err = client.Activate(ctx, &api.ActivateDeviceRequest{
-			DeviceActivation: &api.DeviceActivation{
-				DevEui:      DevEUI,
-				DevAddr:     resp.DevAddr,
-				AppSKey:     key,
-				NwkSEncKey:  key,
-				SNwkSIntKey: key,
-				FNwkSIntKey: key,
-			},
+		if status.Code(err) == codes.NotFound {
+			return false, nil
+		}
+		fmt.Println("dev get fail", err)
+		return false, err
+	}
+	return true, nil
+}
+
+// ListDevices returns all the devices of the application
+func ListDevices(conn *grpc.ClientConn, ctx context.Context, applicationId int64) (devices []*api.DeviceListItem, err error) {
+	client := api.NewDeviceServiceClient(conn)
+	if devices, err = listAll(func(offset int64) ([]*api.DeviceListItem, int64, error) {
+		resp, err := client.List(ctx, &api.ListDeviceRequest{
+			Limit:         Limit64,
+			Offset:        offset,
+			ApplicationId: applicationId,
+		})
+		if err != nil {
+			return nil, 0, err
+		}
+		return resp.Result, resp.TotalCount, nil
+	}); err != nil {
+		fmt.Println("dev list fail", err)
+	}
+	return
+}
+
+// ProfileInUse reports whether a device of any application of the organization uses the device profile, the
+// profiles belong to the organization. The pages stop at the first device found.
+func ProfileInUse(conn *grpc.ClientConn, ctx context.Context, organizationId int64, profileId string) (used bool, err error) {
+	applicationClient := api.NewApplicationServiceClient(conn)
+	var applications []*api.ApplicationListItem
+	if applications, err = listAll(func(offset int64) ([]*api.ApplicationListItem, int64, error) {
+		resp, err := applicationClient.List(ctx, &api.ListApplicationRequest{
+			Limit:          Limit64,
+			Offset:         offset,
+			OrganizationId: organizationId,
+		})
+		if err != nil {
+			return nil, 0, err
+		}
+		return resp.Result, resp.TotalCount, nil
+	}); err != nil {
+		fmt.Println("application list fail", err)
+		return
+	}
+
+	client := api.NewDeviceServiceClient(conn)
+	for _, application := range applications {
+		if err = eachItem(func(offset int64) ([]*api.DeviceListItem, int64, error) {
+			resp, err := client.List(ctx, &api.ListDeviceRequest{
+				Limit:         Limit64,
+				Offset:        offset,
+				ApplicationId: application.Id,
+			})
+			if err != nil {
+				return nil, 0, err
+			}
+			return resp.Result, resp.TotalCount, nil
+		}, func(device *api.DeviceListItem) bool {
+			used = device.DeviceProfileId == profileId
+			return !used
 		}); err != nil {
-			fmt.Println("dev Activate fail", err)
-		} else {
-			fmt.Println("dev activate success")
+			fmt.Println("dev list fail", err)
+			return
+		}
+		if used {
+			return
+		}
+	}
+	return
+}
+
+func ActivateDevice(conn *grpc.ClientConn, ctx context.Context, DevEUI string, devAddr string, appSKey string, nwkSEncKey string, sNwkSIntKey string, fNwkSIntKey string) (err error) {
+	client := api.NewDeviceServiceClient(conn)
+	// 未指定DevAddr时由chirpstack分配
+	if len(devAddr) == 0 {
+		var resp *api.GetRandomDevAddrResponse
+		if resp, err = client.GetRandomDevAddr(ctx, &api.GetRandomDevAddrRequest{
+			DevEui: DevEUI,
+		}); err != nil {
+			fmt.Println("dev get addr fail", err)
+			return
 		}
+		devAddr = resp.DevAddr
+	}
+
+	if _, err = client.Activate(ctx, &api.ActivateDeviceRequest{
+		DeviceActivation: &api.DeviceActivation{
+			DevEui:      DevEUI,
+			DevAddr:     devAddr,
+			AppSKey:     appSKey,
+			NwkSEncKey:  nwkSEncKey,
+			SNwkSIntKey: sNwkSIntKey,
+			FNwkSIntKey: fNwkSIntKey,
+		},
+	}); err != nil {
+		fmt.Println("dev Activate fail", err)
+	} else {
+		fmt.Println("dev activate success")
+	}
+	return
+}
+
+func CreateKeys(conn *grpc.ClientConn, ctx context.Context, DevEUI string, appKey string, nwkKey string) (err error) {
+	client := api.NewDeviceServiceClient(conn)
+	if _, err = client.CreateKeys(ctx, &api.CreateDeviceKeysRequest{
+		DeviceKeys: &api.DeviceKeys{
+			DevEui: DevEUI,
+			NwkKey: nwkKey,
+			AppKey: appKey,
+		},
+	}); err == nil {
+		fmt.Println("dev keys create success")
+	} else {
+		fmt.Println("dev keys create fail", err)
 	}
 	return
 }
 
-func UpdateDevice(conn *grpc.ClientConn, ctx context.Context, DevEUI string, name string) (err error) {
+// HasKeys returns whether the root keys of an OTAA device are set
+func HasKeys(conn *grpc.ClientConn, ctx context.Context, DevEUI string) (exists bool, err error) {
+	client := api.NewDeviceServiceClient(conn)
+	var resp *api.GetDeviceKeysResponse
+	if resp, err = client.GetKeys(ctx, &api.GetDeviceKeysRequest{
+		DevEui: DevEUI,
+	}); err != nil {
+		if status.Code(err) == codes.NotFound {
+			return false, nil
+		}
+		fmt.Println("dev keys get fail", err)
+		return false, err
+	}
+	return resp.DeviceKeys != nil, nil
+}
+
+// HasActivation returns whether an ABP device is activated
+func HasActivation(conn *grpc.ClientConn, ctx context.Context, DevEUI string) (activated bool, err error) {
+	client := api.NewDeviceServiceClient(conn)
+	var resp *api.GetDeviceActivationResponse
+	if resp, err = client.GetActivation(ctx, &api.GetDeviceActivationRequest{
+		DevEui: DevEUI,
+	}); err != nil {
+		if status.Code(err) == codes.NotFound {
+			return false, nil
+		}
+		fmt.Println("dev activation get fail", err)
+		return false, err
+	}
+	return resp.DeviceActivation != nil && len(resp.DeviceActivation.DevAddr) > 0, nil
+}
+
+// UpdateDevice updates the name, device profile and disabled state of a device, an empty deviceProfileId keeps
+// the device profile and the other settings of the device are kept
+func UpdateDevice(conn *grpc.ClientConn, ctx context.Context, DevEUI string, name string, deviceProfileId string, disabled bool) (err error) {
 	client := api.NewDeviceServiceClient(conn)
 
 	var resp *api.GetDeviceResponse
 	if resp, err = client.Get(ctx, &api.GetDeviceRequest{
 		DevEui: DevEUI,
 	}); err == nil && resp.Device != nil {
-		if _, err := client.Update(ctx, &api.UpdateDeviceRequest{
-			Device: &api.Device{
-				DevEui:          DevEUI,
-				Name:            name,
-				ApplicationId:   resp.Device.ApplicationId,
-				DeviceProfileId: resp.Device.DeviceProfileId,
-				IsDisabled:      false,
-				SkipFCntCheck:   true,
-			},
+		device := resp.Device
+		device.Name = name
+		// 未指定profile时保留原profile
+		if len(deviceProfileId) > 0 {
+			device.DeviceProfileId = deviceProfileId
+		}
+		// 禁用的设备由network server丢弃其上行
+		device.IsDisabled = disabled
+		device.SkipFCntCheck = true
+		if _, err = client.Update(ctx, &api.UpdateDeviceRequest{
+			Device: device,
 		}); err != nil {
 			fmt.Println("dev update fail", err)
 		} else {
@@ -343,3 +631,60 @@ func DeleteDevice(conn *grpc.ClientConn, ctx context.Context, deviceName string,
 	}
 	return
 }
+
+func Enqueue(conn *grpc.ClientConn, ctx context.Context, DevEUI string, fPort uint32, confirmed bool, data []byte, object string) (fCnt uint32, err error) {
+	client := api.NewDeviceQueueServiceClient(conn)
+	var resp *api.EnqueueDeviceQueueItemResponse
+	if resp, err = client.Enqueue(ctx, &api.EnqueueDeviceQueueItemRequest{
+		DeviceQueueItem: &api.DeviceQueueItem{
+			DevEui:     DevEUI,
+			Confirmed:  confirmed,
+			FPort:      fPort,
+			Data:       data,
+			JsonObject: object,
+		},
+	}); err != nil {
+		fmt.Println("dev enqueue fail", err)
+		return
+	}
+
+	fmt.Println("dev enqueue success")
+	return resp.FCnt, nil
+}
+
+func ListQueue(conn *grpc.ClientConn, ctx context.Context, DevEUI string) (items []*api.DeviceQueueItem, err error) {
+	client := api.NewDeviceQueueServiceClient(conn)
+	var resp *api.ListDeviceQueueItemsResponse
+	if resp, err = client.List(ctx, &api.ListDeviceQueueItemsRequest{
+		DevEui: DevEUI,
+	}); err != nil {
+		fmt.Println("dev queue list fail", err)
+		return
+	}
+	return resp.DeviceQueueItems, nil
+}
+
+func CountQueue(conn *grpc.ClientConn, ctx context.Context, DevEUI string) (count uint32, err error) {
+	client := api.NewDeviceQueueServiceClient(conn)
+	var resp *api.ListDeviceQueueItemsResponse
+	if resp, err = client.List(ctx, &api.ListDeviceQueueItemsRequest{
+		DevEui:    DevEUI,
+		CountOnly: true,
+	}); err != nil {
+		fmt.Println("dev queue count fail", err)
+		return
+	}
+	return resp.TotalCount, nil
+}
+
+func FlushQueue(conn *grpc.ClientConn, ctx context.Context, DevEUI string) (err error) {
+	client := api.NewDeviceQueueServiceClient(conn)
+	if _, err = client.Flush(ctx, &api.FlushDeviceQueueRequest{
+		DevEui: DevEUI,
+	}); err == nil {
+		fmt.Println("dev queue flush success")
+	} else {
+		fmt.Println("dev queue flush fail", err)
+	}
+	return
+}
@@ -0,0 +1,131 @@
+package v3
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/brocaar/chirpstack-api/go/v3/as/external/api"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// fakeProfileService returns the profiles page by page and records the requested offsets
+type fakeProfileService struct {
+	api.UnimplementedDeviceProfileServiceServer
+	names   []string
+	offsets []int64
+}
+
+func (s *fakeProfileService) List(ctx context.Context, req *api.ListDeviceProfileRequest) (*api.ListDeviceProfileResponse, error) {
+	s.offsets = append(s.offsets, req.Offset)
+	profiles := make([]*api.DeviceProfileListItem, len(s.names))
+	for i, name := range s.names {
+		profiles[i] = &api.DeviceProfileListItem{Id: fmt.Sprintf("profile-%d", i), Name: name}
+	}
+	return &api.ListDeviceProfileResponse{TotalCount: int64(len(profiles)), Result: page(profiles, req.Offset, req.Limit)}, nil
+}
+
+// fakeGatewayService returns count gateways page by page
+type fakeGatewayService struct {
+	api.UnimplementedGatewayServiceServer
+	count int
+}
+
+func (s *fakeGatewayService) List(ctx context.Context, req *api.ListGatewayRequest) (*api.ListGatewayResponse, error) {
+	gateways := make([]*api.GatewayListItem, s.count)
+	for i := range gateways {
+		gateways[i] = &api.GatewayListItem{Id: fmt.Sprintf("%016x", i)}
+	}
+	return &api.ListGatewayResponse{TotalCount: int64(s.count), Result: page(gateways, int64(req.Offset), int64(req.Limit))}, nil
+}
+
+func page[T any](items []T, offset int64, limit int64) []T {
+	if int(offset) >= len(items) {
+		return nil
+	}
+	end := int(offset + limit)
+	if end > len(items) {
+		end = len(items)
+	}
+	return items[offset:end]
+}
+
+// dialFake connects to an in-memory server of the fake services
+func dialFake(t *testing.T, register func(server *grpc.Server)) *grpc.ClientConn {
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	register(server)
+	go server.Serve(listener) //nolint:errcheck
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial("bufnet", grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestFindProfile(t *testing.T) {
+	names := make([]string, 0, 231)
+	for i := 0; i < 230; i++ {
+		names = append(names, fmt.Sprintf("Lora Device %03d", i))
+	}
+	names = append(names, "Lora Device")
+
+	tests := []struct {
+		name    string
+		find    string
+		id      string
+		offsets []int64
+	}{
+		{"last page", "Lora Device", "profile-230", []int64{0, 100, 200}},
+		{"first page", "Lora Device 001", "profile-1", []int64{0}},
+		{"not found", "Lora Device CO2", "", []int64{0, 100, 200}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &fakeProfileService{names: names}
+			conn := dialFake(t, func(server *grpc.Server) { api.RegisterDeviceProfileServiceServer(server, service) })
+
+			id, err := FindProfile(conn, context.Background(), 1, 1, tt.find)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if id != tt.id {
+				t.Errorf("expected id %q, got %q", tt.id, id)
+			}
+			if fmt.Sprint(service.offsets) != fmt.Sprint(tt.offsets) {
+				t.Errorf("expected offsets %v, got %v", tt.offsets, service.offsets)
+			}
+		})
+	}
+}
+
+func TestListGateways(t *testing.T) {
+	for _, count := range []int{0, 99, 100, 250} {
+		t.Run(fmt.Sprint(count), func(t *testing.T) {
+			conn := dialFake(t, func(server *grpc.Server) { api.RegisterGatewayServiceServer(server, &fakeGatewayService{count: count}) })
+
+			gateways, err := ListGateways(conn, context.Background(), 1)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(gateways) != count {
+				t.Fatalf("expected %d gateways, got %d", count, len(gateways))
+			}
+			for i, gateway := range gateways {
+				if gateway.Id != fmt.Sprintf("%016x", i) {
+					t.Fatalf("expected gateway %d, got %s", i, gateway.Id)
+				}
+			}
+		})
+	}
+}
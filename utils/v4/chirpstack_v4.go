@@ -1,6 +1,3 @@
-//go:build chirpstack4
-// +build chirpstack4
-
 package v4
 
 import (
@@ -8,157 +5,262 @@ import (
 	"fmt"
 
 	"github.com/chirpstack/chirpstack/api/go/v4/api"
-	csCommon "github.com/chirpstack/chirpstack/api/go/v4/common"
-	"github.com/edgexfoundry/device-lora-go/config"
 	uuid "github.com/satori/go.uuid"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
 )
 
 var (
 	Limit uint32 = 100
 )
 
-func Init(conn *grpc.ClientConn, config config.ChirpStackConfig) (tenantId, appId string, err error) {
-	var ctx context.Context
-	// 登录chirpstack
-	if ctx, err = Login(conn, config.Username, config.Password); err != nil {
-		return
-	}
+// Scope selects the tenant and application of the devices. The configured ids are verified, otherwise the tenant and
+// application of the name, or the only one when the name is empty, are used. AutoCreate creates the missing tenant
+// and application with the names.
+type Scope struct {
+	TenantId        string
+	TenantName      string
+	ApplicationId   string
+	ApplicationName string
+	AutoCreate      bool
+}
+
+// Init returns the tenant and application of the devices
+func Init(conn *grpc.ClientConn, ctx context.Context, scope Scope) (tenantId string, appId string, err error) {
 	// 获取tentant
-	if tenantId, err = initTenant(conn, ctx); err != nil {
-		return
+	if tenantId, err = initTenant(conn, ctx, scope); err != nil {
+		return "", "", fmt.Errorf("unable to get tenant: %v", err)
 	}
 	// 获取application
-	if appId, err = initApplication(conn, ctx, tenantId); err != nil {
-		return
+	if appId, err = initApplication(conn, ctx, tenantId, scope); err != nil {
+		return "", "", fmt.Errorf("unable to get application: %v", err)
 	}
 
-	return
+	return tenantId, appId, nil
 }
 
-func Login(conn *grpc.ClientConn, username, password string) (ctx context.Context, err error) {
+func Login(conn *grpc.ClientConn, username, password string) (jwt string, err error) {
 	client := api.NewInternalServiceClient(conn)
 	var resp *api.LoginResponse
 	if resp, err = client.Login(context.Background(), &api.LoginRequest{
 		Email:    username,
 		Password: password,
 	}); err != nil {
-		return nil, err
+		return "", err
 	}
 
-	fmt.Println("jwt", resp.Jwt)
-	ctx = metadata.NewOutgoingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+resp.Jwt))
-
-	return
+	return resp.Jwt, nil
 }
 
-func initTenant(conn *grpc.ClientConn, ctx context.Context) (id string, err error) {
+func initTenant(conn *grpc.ClientConn, ctx context.Context, scope Scope) (id string, err error) {
 	client := api.NewTenantServiceClient(conn)
-	var resp *api.ListTenantsResponse
-	if resp, err = client.List(ctx, &api.ListTenantsRequest{
-		Limit: Limit,
-	}); err != nil {
-		return "", err
+	// 配置的tenant需存在
+	if len(scope.TenantId) > 0 {
+		if _, err = client.Get(ctx, &api.GetTenantRequest{
+			Id: scope.TenantId,
+		}); err != nil {
+			return "", fmt.Errorf("tenant %s: %v", scope.TenantId, err)
+		}
+		return scope.TenantId, nil
 	}
-	if len(resp.Result) > 0 {
-		return resp.Result[0].Id, nil
-	} else {
-		var resp *api.CreateTenantResponse
-		if resp, err = client.Create(ctx, &api.CreateTenantRequest{
-			Tenant: &api.Tenant{
-				Id:   uuid.NewV4().String(),
-				Name: "Starblaze",
-			},
-		}); err == nil {
-			return resp.Id, nil
+
+	var tenants []*api.TenantListItem
+	if tenants, err = listAll(func(offset uint32) ([]*api.TenantListItem, uint32, error) {
+		resp, err := client.List(ctx, &api.ListTenantsRequest{
+			Limit:  Limit,
+			Offset: offset,
+			Search: scope.TenantName,
+		})
+		if err != nil {
+			return nil, 0, err
 		}
+		return resp.Result, resp.TotalCount, nil
+	}); err != nil {
+		return "", fmt.Errorf("%v, ChirpStack.TenantId is required for tenant API tokens", err)
+	}
+	var found bool
+	if id, found, err = findItem(tenants, scope.TenantName, func(tenant *api.TenantListItem) (string, string) {
+		return tenant.Name, tenant.Id
+	}); err != nil || found {
+		return
 	}
 
-	return "", err
+	// 不存在时按配置创建
+	if !scope.AutoCreate || len(scope.TenantName) == 0 {
+		return "", fmt.Errorf("tenant %s not found, ChirpStack.AutoCreate and ChirpStack.TenantName create it", scope.TenantName)
+	}
+	var resp *api.CreateTenantResponse
+	if resp, err = client.Create(ctx, &api.CreateTenantRequest{
+		Tenant: &api.Tenant{
+			Id:   uuid.NewV4().String(),
+			Name: scope.TenantName,
+		},
+	}); err != nil {
+		return "", err
+	}
+	return resp.Id, nil
 }
 
-func initApplication(conn *grpc.ClientConn, ctx context.Context, tenantId string) (id string, err error) {
+func initApplication(conn *grpc.ClientConn, ctx context.Context, tenantId string, scope Scope) (id string, err error) {
 	client := api.NewApplicationServiceClient(conn)
+	// 配置的application需存在且属于该tenant
+	if len(scope.ApplicationId) > 0 {
+		var resp *api.GetApplicationResponse
+		if resp, err = client.Get(ctx, &api.GetApplicationRequest{
+			Id: scope.ApplicationId,
+		}); err != nil {
+			return "", fmt.Errorf("application %s: %v", scope.ApplicationId, err)
+		}
+		if resp.Application.TenantId != tenantId {
+			return "", fmt.Errorf("application %s doesn't belong to tenant %s", scope.ApplicationId, tenantId)
+		}
+		return scope.ApplicationId, nil
+	}
 
-	var resp *api.ListApplicationsResponse
-	if resp, err = client.List(ctx, &api.ListApplicationsRequest{
-		Limit:    Limit,
-		TenantId: tenantId,
+	var applications []*api.ApplicationListItem
+	if applications, err = listAll(func(offset uint32) ([]*api.ApplicationListItem, uint32, error) {
+		resp, err := client.List(ctx, &api.ListApplicationsRequest{
+			Limit:    Limit,
+			Offset:   offset,
+			Search:   scope.ApplicationName,
+			TenantId: tenantId,
+		})
+		if err != nil {
+			return nil, 0, err
+		}
+		return resp.Result, resp.TotalCount, nil
 	}); err != nil {
 		return "", err
 	}
-
-	if len(resp.Result) > 0 {
-		return resp.Result[0].Id, nil
-	} else {
-		var resp *api.CreateApplicationResponse
-		if resp, err = client.Create(ctx, &api.CreateApplicationRequest{
-			Application: &api.Application{
-				Id:       uuid.NewV4().String(),
-				Name:     "Starblaze App",
-				TenantId: tenantId,
-			},
-		}); err == nil {
-			return resp.Id, nil
-		}
+	var found bool
+	if id, found, err = findItem(applications, scope.ApplicationName, func(application *api.ApplicationListItem) (string, string) {
+		return application.Name, application.Id
+	}); err != nil || found {
+		return
 	}
 
-	return "", err
+	// 不存在时按配置创建
+	if !scope.AutoCreate || len(scope.ApplicationName) == 0 {
+		return "", fmt.Errorf("application %s not found, ChirpStack.AutoCreate and ChirpStack.ApplicationName create it", scope.ApplicationName)
+	}
+	var resp *api.CreateApplicationResponse
+	if resp, err = client.Create(ctx, &api.CreateApplicationRequest{
+		Application: &api.Application{
+			Id:       uuid.NewV4().String(),
+			Name:     scope.ApplicationName,
+			TenantId: tenantId,
+		},
+	}); err != nil {
+		return "", err
+	}
+	return resp.Id, nil
 }
 
-func CreateProfile(conn *grpc.ClientConn, ctx context.Context, tenantId string, name string, codec string) (id string, err error) {
-	client := api.NewDeviceProfileServiceClient(conn)
-	var resp *api.ListDeviceProfilesResponse
-	if resp, err = client.List(ctx, &api.ListDeviceProfilesRequest{
-		Limit:    Limit,
-		TenantId: tenantId,
-		Search:   name,
-	}); err == nil && resp.Result != nil {
-		fmt.Println("profiles", resp.Result)
-		if len(resp.Result) > 0 && resp.Result[0] != nil {
-			//返回已存在的profile
-			return resp.Result[0].Id, nil
-		}
+func CreateProfile(conn *grpc.ClientConn, ctx context.Context, tenantId string, profile *api.DeviceProfile) (id string, err error) {
+	//返回已存在的profile
+	if id, err = FindProfile(conn, ctx, tenantId, profile.Name); err != nil || len(id) > 0 {
+		return
 	}
 
 	//创建profile
-	var resp1 *api.CreateDeviceProfileResponse
-	if resp1, err = client.Create(ctx, &api.CreateDeviceProfileRequest{
-		DeviceProfile: &api.DeviceProfile{
-			Id:                  uuid.NewV4().String(),
-			TenantId:            tenantId,
-			Name:                name,
-			Region:              csCommon.Region_CN470,
-			MacVersion:          csCommon.MacVersion_LORAWAN_1_0_2,
-			RegParamsRevision:   csCommon.RegParamsRevision_A,
-			AdrAlgorithmId:      "default", // options: default, lr_fhss, lora_lr_fhss
-			UplinkInterval:      600,
-			PayloadCodecScript:  codec,
-			PayloadCodecRuntime: api.CodecRuntime_JS,
-		},
+	client := api.NewDeviceProfileServiceClient(conn)
+	profile.Id = uuid.NewV4().String()
+	profile.TenantId = tenantId
+	var resp *api.CreateDeviceProfileResponse
+	if resp, err = client.Create(ctx, &api.CreateDeviceProfileRequest{
+		DeviceProfile: profile,
 	}); err == nil {
-		return resp1.Id, nil
+		return resp.Id, nil
 	}
 
 	return "", err
 }
 
+// DeleteProfile deletes the device profile of the name, nothing is done when it doesn't exist
 func DeleteProfile(conn *grpc.ClientConn, ctx context.Context, tenantId string, name string) (err error) {
+	var id string
+	if id, err = FindProfile(conn, ctx, tenantId, name); err != nil || len(id) == 0 {
+		return
+	}
+
 	client := api.NewDeviceProfileServiceClient(conn)
-	var resp *api.ListDeviceProfilesResponse
-	if resp, err = client.List(ctx, &api.ListDeviceProfilesRequest{
-		Limit:    Limit,
-		TenantId: tenantId,
-		Search:   name,
-	}); err == nil && resp.Result != nil {
-		for _, profile := range resp.Result {
-			if _, err = client.Delete(ctx, &api.DeleteDeviceProfileRequest{
-				Id: profile.Id,
-			}); err == nil {
-				fmt.Println("profile delete success")
-			}
+	if _, err = client.Delete(ctx, &api.DeleteDeviceProfileRequest{
+		Id: id,
+	}); err != nil {
+		fmt.Println("profile delete fail", err)
+		return
+	}
+	fmt.Println("profile delete success")
+	return
+}
+
+// FindProfile returns the id of the device profile of the name, it is empty when the profile doesn't exist.
+// The search of ChirpStack matches substrings, so every page of the results is checked for the exact name.
+func FindProfile(conn *grpc.ClientConn, ctx context.Context, tenantId string, name string) (id string, err error) {
+	client := api.NewDeviceProfileServiceClient(conn)
+	if err = eachItem(func(offset uint32) ([]*api.DeviceProfileListItem, uint32, error) {
+		resp, err := client.List(ctx, &api.ListDeviceProfilesRequest{
+			Limit:    Limit,
+			Offset:   offset,
+			TenantId: tenantId,
+			Search:   name,
+		})
+		if err != nil {
+			return nil, 0, err
+		}
+		return resp.Result, resp.TotalCount, nil
+	}, func(profile *api.DeviceProfileListItem) bool {
+		if profile.Name == name {
+			id = profile.Id
+			return false
+		}
+		return true
+	}); err != nil {
+		fmt.Println("profile list fail", err)
+	}
+	return
+}
+
+func UpdateProfile(conn *grpc.ClientConn, ctx context.Context, profile *api.DeviceProfile) (err error) {
+	client := api.NewDeviceProfileServiceClient(conn)
+	if _, err = client.Update(ctx, &api.UpdateDeviceProfileRequest{
+		DeviceProfile: profile,
+	}); err != nil {
+		fmt.Println("profile update fail", err)
+	}
+	return
+}
+
+func GetProfile(conn *grpc.ClientConn, ctx context.Context, id string) (profile *api.DeviceProfile, err error) {
+	client := api.NewDeviceProfileServiceClient(conn)
+	var resp *api.GetDeviceProfileResponse
+	if resp, err = client.Get(ctx, &api.GetDeviceProfileRequest{
+		Id: id,
+	}); err != nil {
+		fmt.Println("profile get fail", err)
+		return
+	}
+	return resp.DeviceProfile, nil
+}
+
+// ListGateways returns all the gateways of the tenant
+func ListGateways(conn *grpc.ClientConn, ctx context.Context, tenantId string) (gateways []*api.GatewayListItem, err error) {
+	client := api.NewGatewayServiceClient(conn)
+	if gateways, err = listAll(func(offset uint32) ([]*api.GatewayListItem, uint32, error) {
+		resp, err := client.List(ctx, &api.ListGatewaysRequest{
+			Limit:    Limit,
+			Offset:   offset,
+			TenantId: tenantId,
+		})
+		if err != nil {
+			return nil, 0, err
 		}
+		return resp.Result, resp.TotalCount, nil
+	}); err != nil {
+		fmt.Println("gateway list fail", err)
 	}
 	return
 }
@@ -227,7 +329,7 @@ func DeleteGateway(conn *grpc.ClientConn, ctx context.Context, gateWayId string)
 	return
 }
 
-func CreateDevice(conn *grpc.ClientConn, ctx context.Context, DevEUI string, name string, deviceProfileId string, applicationId string) (err error) {
+func CreateDevice(conn *grpc.ClientConn, ctx context.Context, DevEUI string, name string, deviceProfileId string, disabled bool, applicationId string) (err error) {
 	client := api.NewDeviceServiceClient(conn)
 	if _, err = client.Create(ctx, &api.CreateDeviceRequest{
 		Device: &api.Device{
@@ -235,6 +337,7 @@ func CreateDevice(conn *grpc.ClientConn, ctx context.Context, DevEUI string, nam
 			Name:            name,
 			ApplicationId:   applicationId,
 			DeviceProfileId: deviceProfileId,
+			IsDisabled:      disabled,
 			SkipFcntCheck:   true,
 		},
 	}); err == nil {
@@ -247,48 +350,185 @@ func CreateDevice(conn *grpc.ClientConn, ctx context.Context, DevEUI string, nam
 	return
 }
 
-func ActivateDevice(conn *grpc.ClientConn, ctx context.Context, DevEUI string, key string) (err error) {
+// DeviceExists returns whether the device is registered in ChirpStack
+func DeviceExists(conn *grpc.ClientConn, ctx context.Context, DevEUI string) (exists bool, err error) {
 	client := api.NewDeviceServiceClient(conn)
-	var resp *api.GetRandomDevAddrResponse
-	if resp, err = client.GetRandomDevAddr(ctx, &api.GetRandomDevAddrRequest{
+	if _, err = client.Get(ctx, &api.GetDeviceRequest{
 		DevEui: DevEUI,
 	}); err != nil {
-		fmt.Println("dev get addr fail", err)
-	} else {
-		fmt.Println("dev get addr", resp.DevAddr)
-		if _, err = client.Activate(ctx, &api.ActivateDeviceRequest{
-			DeviceActivation: &api.DeviceActivation{
-				DevEui:      DevEUI,
-				DevAddr:     resp.DevAddr,
-				AppSKey:     key,
-				NwkSEncKey:  key,
-				SNwkSIntKey: key,
-				FNwkSIntKey: key,
-			},
+		if status.Code(err) == codes.NotFound {
+			return false, nil
+		}
+		fmt.Println("dev get fail", err)
+		return false, err
+	}
+	return true, nil
+}
+
+// ListDevices returns all the devices of the application
+func ListDevices(conn *grpc.ClientConn, ctx context.Context, applicationId string) (devices []*api.DeviceListItem, err error) {
+	client := api.NewDeviceServiceClient(conn)
+	if devices, err = listAll(func(offset uint32) ([]*api.DeviceListItem, uint32, error) {
+		resp, err := client.List(ctx, &api.ListDevicesRequest{
+			Limit:         Limit,
+			Offset:        offset,
+			ApplicationId: applicationId,
+		})
+		if err != nil {
+			return nil, 0, err
+		}
+		return resp.Result, resp.TotalCount, nil
+	}); err != nil {
+		fmt.Println("dev list fail", err)
+	}
+	return
+}
+
+// ProfileInUse reports whether a device of any application of the tenant uses the device profile, the profiles
+// belong to the tenant and deleting one deletes its devices too. The pages stop at the first device found.
+func ProfileInUse(conn *grpc.ClientConn, ctx context.Context, tenantId string, profileId string) (used bool, err error) {
+	applicationClient := api.NewApplicationServiceClient(conn)
+	var applications []*api.ApplicationListItem
+	if applications, err = listAll(func(offset uint32) ([]*api.ApplicationListItem, uint32, error) {
+		resp, err := applicationClient.List(ctx, &api.ListApplicationsRequest{
+			Limit:    Limit,
+			Offset:   offset,
+			TenantId: tenantId,
+		})
+		if err != nil {
+			return nil, 0, err
+		}
+		return resp.Result, resp.TotalCount, nil
+	}); err != nil {
+		fmt.Println("application list fail", err)
+		return
+	}
+
+	client := api.NewDeviceServiceClient(conn)
+	for _, application := range applications {
+		if err = eachItem(func(offset uint32) ([]*api.DeviceListItem, uint32, error) {
+			resp, err := client.List(ctx, &api.ListDevicesRequest{
+				Limit:         Limit,
+				Offset:        offset,
+				ApplicationId: application.Id,
+			})
+			if err != nil {
+				return nil, 0, err
+			}
+			return resp.Result, resp.TotalCount, nil
+		}, func(device *api.DeviceListItem) bool {
+			used = device.DeviceProfileId == profileId
+			return !used
 		}); err != nil {
-			fmt.Println("dev Activate fail", err)
-		} else {
-			fmt.Println("dev activate success")
+			fmt.Println("dev list fail", err)
+			return
+		}
+		if used {
+			return
 		}
 	}
 	return
 }
 
-func UpdateDevice(conn *grpc.ClientConn, ctx context.Context, DevEUI string, name string) (err error) {
+func ActivateDevice(conn *grpc.ClientConn, ctx context.Context, DevEUI string, devAddr string, appSKey string, nwkSEncKey string, sNwkSIntKey string, fNwkSIntKey string) (err error) {
+	client := api.NewDeviceServiceClient(conn)
+	// 未指定DevAddr时由chirpstack分配
+	if len(devAddr) == 0 {
+		var resp *api.GetRandomDevAddrResponse
+		if resp, err = client.GetRandomDevAddr(ctx, &api.GetRandomDevAddrRequest{
+			DevEui: DevEUI,
+		}); err != nil {
+			fmt.Println("dev get addr fail", err)
+			return
+		}
+		devAddr = resp.DevAddr
+	}
+
+	if _, err = client.Activate(ctx, &api.ActivateDeviceRequest{
+		DeviceActivation: &api.DeviceActivation{
+			DevEui:      DevEUI,
+			DevAddr:     devAddr,
+			AppSKey:     appSKey,
+			NwkSEncKey:  nwkSEncKey,
+			SNwkSIntKey: sNwkSIntKey,
+			FNwkSIntKey: fNwkSIntKey,
+		},
+	}); err != nil {
+		fmt.Println("dev Activate fail", err)
+	} else {
+		fmt.Println("dev activate success")
+	}
+	return
+}
+
+func CreateKeys(conn *grpc.ClientConn, ctx context.Context, DevEUI string, appKey string, nwkKey string) (err error) {
+	client := api.NewDeviceServiceClient(conn)
+	if _, err = client.CreateKeys(ctx, &api.CreateDeviceKeysRequest{
+		DeviceKeys: &api.DeviceKeys{
+			DevEui: DevEUI,
+			NwkKey: nwkKey,
+			AppKey: appKey,
+		},
+	}); err == nil {
+		fmt.Println("dev keys create success")
+	} else {
+		fmt.Println("dev keys create fail", err)
+	}
+	return
+}
+
+// HasKeys returns whether the root keys of an OTAA device are set
+func HasKeys(conn *grpc.ClientConn, ctx context.Context, DevEUI string) (exists bool, err error) {
+	client := api.NewDeviceServiceClient(conn)
+	var resp *api.GetDeviceKeysResponse
+	if resp, err = client.GetKeys(ctx, &api.GetDeviceKeysRequest{
+		DevEui: DevEUI,
+	}); err != nil {
+		if status.Code(err) == codes.NotFound {
+			return false, nil
+		}
+		fmt.Println("dev keys get fail", err)
+		return false, err
+	}
+	return resp.DeviceKeys != nil, nil
+}
+
+// HasActivation returns whether an ABP device is activated
+func HasActivation(conn *grpc.ClientConn, ctx context.Context, DevEUI string) (activated bool, err error) {
+	client := api.NewDeviceServiceClient(conn)
+	var resp *api.GetDeviceActivationResponse
+	if resp, err = client.GetActivation(ctx, &api.GetDeviceActivationRequest{
+		DevEui: DevEUI,
+	}); err != nil {
+		if status.Code(err) == codes.NotFound {
+			return false, nil
+		}
+		fmt.Println("dev activation get fail", err)
+		return false, err
+	}
+	return resp.DeviceActivation != nil && len(resp.DeviceActivation.DevAddr) > 0, nil
+}
+
+// UpdateDevice updates the name, device profile and disabled state of a device, an empty deviceProfileId keeps
+// the device profile and the other settings of the device are kept
+func UpdateDevice(conn *grpc.ClientConn, ctx context.Context, DevEUI string, name string, deviceProfileId string, disabled bool) (err error) {
 	client := api.NewDeviceServiceClient(conn)
 
 	var resp *api.GetDeviceResponse
 	if resp, err = client.Get(ctx, &api.GetDeviceRequest{
 		DevEui: DevEUI,
 	}); err == nil && resp.Device != nil {
-		if _, err := client.Update(ctx, &api.UpdateDeviceRequest{
-			Device: &api.Device{
-				DevEui:          DevEUI,
-				Name:            name,
-				ApplicationId:   resp.Device.ApplicationId,
-				DeviceProfileId: resp.Device.DeviceProfileId,
-				SkipFcntCheck:   true,
-			},
+		device := resp.Device
+		device.Name = name
+		// 未指定profile时保留原profile
+		if len(deviceProfileId) > 0 {
+			device.DeviceProfileId = deviceProfileId
+		}
+		// 禁用的设备由network server丢弃其上行
+		device.IsDisabled = disabled
+		device.SkipFcntCheck = true
+		if _, err = client.Update(ctx, &api.UpdateDeviceRequest{
+			Device: device,
 		}); err != nil {
 			fmt.Println("dev update fail", err)
 		} else {
@@ -311,3 +551,68 @@ func DeleteDevice(conn *grpc.ClientConn, ctx context.Context, deviceName string,
 	}
 	return
 }
+
+func Enqueue(conn *grpc.ClientConn, ctx context.Context, DevEUI string, fPort uint32, confirmed bool, data []byte, object string) (id string, err error) {
+	var obj *structpb.Struct
+	if len(object) > 0 {
+		obj = &structpb.Struct{}
+		if err = protojson.Unmarshal([]byte(object), obj); err != nil {
+			return "", err
+		}
+	}
+
+	client := api.NewDeviceServiceClient(conn)
+	var resp *api.EnqueueDeviceQueueItemResponse
+	if resp, err = client.Enqueue(ctx, &api.EnqueueDeviceQueueItemRequest{
+		QueueItem: &api.DeviceQueueItem{
+			DevEui:    DevEUI,
+			Confirmed: confirmed,
+			FPort:     fPort,
+			Data:      data,
+			Object:    obj,
+		},
+	}); err != nil {
+		fmt.Println("dev enqueue fail", err)
+		return
+	}
+
+	fmt.Println("dev enqueue success")
+	return resp.Id, nil
+}
+
+func ListQueue(conn *grpc.ClientConn, ctx context.Context, DevEUI string) (items []*api.DeviceQueueItem, err error) {
+	client := api.NewDeviceServiceClient(conn)
+	var resp *api.GetDeviceQueueItemsResponse
+	if resp, err = client.GetQueue(ctx, &api.GetDeviceQueueItemsRequest{
+		DevEui: DevEUI,
+	}); err != nil {
+		fmt.Println("dev queue list fail", err)
+		return
+	}
+	return resp.Result, nil
+}
+
+func CountQueue(conn *grpc.ClientConn, ctx context.Context, DevEUI string) (count uint32, err error) {
+	client := api.NewDeviceServiceClient(conn)
+	var resp *api.GetDeviceQueueItemsResponse
+	if resp, err = client.GetQueue(ctx, &api.GetDeviceQueueItemsRequest{
+		DevEui:    DevEUI,
+		CountOnly: true,
+	}); err != nil {
+		fmt.Println("dev queue count fail", err)
+		return
+	}
+	return resp.TotalCount, nil
+}
+
+func FlushQueue(conn *grpc.ClientConn, ctx context.Context, DevEUI string) (err error) {
+	client := api.NewDeviceServiceClient(conn)
+	if _, err = client.FlushQueue(ctx, &api.FlushDeviceQueueRequest{
+		DevEui: DevEUI,
+	}); err == nil {
+		fmt.Println("dev queue flush success")
+	} else {
+		fmt.Println("dev queue flush fail", err)
+	}
+	return
+}
@@ -0,0 +1,125 @@
+package v4
+
+import (
+	"context"
+	"testing"
+
+	"github.com/chirpstack/chirpstack/api/go/v4/api"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeTenantService holds tenants keyed by id and records the created names
+type fakeTenantService struct {
+	api.UnimplementedTenantServiceServer
+	tenants map[string]string
+	created []string
+}
+
+func (s *fakeTenantService) Get(ctx context.Context, req *api.GetTenantRequest) (*api.GetTenantResponse, error) {
+	if name, ok := s.tenants[req.Id]; ok {
+		return &api.GetTenantResponse{Tenant: &api.Tenant{Id: req.Id, Name: name}}, nil
+	}
+	return nil, status.Error(codes.NotFound, "object does not exist")
+}
+
+func (s *fakeTenantService) List(ctx context.Context, req *api.ListTenantsRequest) (*api.ListTenantsResponse, error) {
+	var tenants []*api.TenantListItem
+	for id, name := range s.tenants {
+		tenants = append(tenants, &api.TenantListItem{Id: id, Name: name})
+	}
+	return &api.ListTenantsResponse{TotalCount: uint32(len(tenants)), Result: page(tenants, req.Offset, req.Limit)}, nil
+}
+
+func (s *fakeTenantService) Create(ctx context.Context, req *api.CreateTenantRequest) (*api.CreateTenantResponse, error) {
+	s.created = append(s.created, req.Tenant.Name)
+	return &api.CreateTenantResponse{Id: "created-tenant"}, nil
+}
+
+// fakeApplicationService holds applications keyed by id, all in tenant-1
+type fakeApplicationService struct {
+	api.UnimplementedApplicationServiceServer
+	applications map[string]string
+	created      []string
+}
+
+func (s *fakeApplicationService) Get(ctx context.Context, req *api.GetApplicationRequest) (*api.GetApplicationResponse, error) {
+	if name, ok := s.applications[req.Id]; ok {
+		return &api.GetApplicationResponse{Application: &api.Application{Id: req.Id, Name: name, TenantId: "tenant-1"}}, nil
+	}
+	return nil, status.Error(codes.NotFound, "object does not exist")
+}
+
+func (s *fakeApplicationService) List(ctx context.Context, req *api.ListApplicationsRequest) (*api.ListApplicationsResponse, error) {
+	var applications []*api.ApplicationListItem
+	if req.TenantId == "tenant-1" {
+		for id, name := range s.applications {
+			applications = append(applications, &api.ApplicationListItem{Id: id, Name: name})
+		}
+	}
+	return &api.ListApplicationsResponse{TotalCount: uint32(len(applications)), Result: page(applications, req.Offset, req.Limit)}, nil
+}
+
+func (s *fakeApplicationService) Create(ctx context.Context, req *api.CreateApplicationRequest) (*api.CreateApplicationResponse, error) {
+	s.created = append(s.created, req.Application.Name)
+	return &api.CreateApplicationResponse{Id: "created-application"}, nil
+}
+
+func TestInit(t *testing.T) {
+	tests := []struct {
+		name         string
+		tenants      map[string]string
+		applications map[string]string
+		scope        Scope
+		tenantId     string
+		appId        string
+		created      int
+		err          bool
+	}{
+		{"configured ids", map[string]string{"tenant-1": "EdgeX", "tenant-2": "Other"}, map[string]string{"app-1": "LoRa", "app-2": "Other"},
+			Scope{TenantId: "tenant-1", ApplicationId: "app-2"}, "tenant-1", "app-2", 0, false},
+		{"unknown tenant id", map[string]string{"tenant-1": "EdgeX"}, map[string]string{"app-1": "LoRa"},
+			Scope{TenantId: "tenant-3"}, "", "", 0, true},
+		{"application of other tenant", map[string]string{"tenant-1": "EdgeX", "tenant-2": "Other"}, map[string]string{"app-1": "LoRa"},
+			Scope{TenantId: "tenant-2", ApplicationId: "app-1"}, "", "", 0, true},
+		{"names", map[string]string{"tenant-1": "EdgeX", "tenant-2": "EdgeX 2"}, map[string]string{"app-1": "LoRa", "app-2": "LoRa 2"},
+			Scope{TenantName: "EdgeX", ApplicationName: "LoRa 2"}, "tenant-1", "app-2", 0, false},
+		{"only ones", map[string]string{"tenant-1": "EdgeX"}, map[string]string{"app-1": "LoRa"},
+			Scope{}, "tenant-1", "app-1", 0, false},
+		{"ambiguous tenant", map[string]string{"tenant-1": "EdgeX", "tenant-2": "Other"}, map[string]string{"app-1": "LoRa"},
+			Scope{}, "", "", 0, true},
+		{"missing application", map[string]string{"tenant-1": "EdgeX"}, map[string]string{},
+			Scope{ApplicationName: "LoRa"}, "", "", 0, true},
+		{"auto create", map[string]string{}, map[string]string{},
+			Scope{TenantName: "EdgeX", ApplicationName: "LoRa", AutoCreate: true}, "created-tenant", "created-application", 2, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tenants := &fakeTenantService{tenants: tt.tenants}
+			applications := &fakeApplicationService{applications: tt.applications}
+			conn := dialFake(t, func(server *grpc.Server) {
+				api.RegisterTenantServiceServer(server, tenants)
+				api.RegisterApplicationServiceServer(server, applications)
+			})
+
+			tenantId, appId, err := Init(conn, context.Background(), tt.scope)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected error, got tenant %s and application %s", tenantId, appId)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tenantId != tt.tenantId || appId != tt.appId {
+				t.Errorf("expected tenant %s and application %s, got %s and %s", tt.tenantId, tt.appId, tenantId, appId)
+			}
+			if created := len(tenants.created) + len(applications.created); created != tt.created {
+				t.Errorf("expected %d created, got %d", tt.created, created)
+			}
+		})
+	}
+}
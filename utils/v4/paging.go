@@ -0,0 +1,51 @@
+package v4
+
+import "fmt"
+
+// eachItem walks the pages of a List call, list returns the items of the page at the offset and the total count.
+// visit is called with every item until it returns false, the last page is a short page or reaches the total count.
+func eachItem[T any](list func(offset uint32) (items []T, total uint32, err error), visit func(item T) bool) error {
+	for offset := uint32(0); ; offset += Limit {
+		items, total, err := list(offset)
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			if !visit(item) {
+				return nil
+			}
+		}
+		if len(items) < int(Limit) || offset+uint32(len(items)) >= total {
+			return nil
+		}
+	}
+}
+
+// listAll returns the items of every page of a List call, nothing when a page fails
+func listAll[T any](list func(offset uint32) (items []T, total uint32, err error)) (all []T, err error) {
+	if err = eachItem(list, func(item T) bool {
+		all = append(all, item)
+		return true
+	}); err != nil {
+		return nil, err
+	}
+	return all, nil
+}
+
+// findItem returns the id of the item of the name, or of the only item when the name is empty. found is false when
+// there is no such item, more than one item is an error when the name is empty.
+func findItem[T any, I any](items []T, name string, item func(T) (string, I)) (id I, found bool, err error) {
+	var ids []I
+	for _, it := range items {
+		if itemName, itemId := item(it); len(name) == 0 || itemName == name {
+			ids = append(ids, itemId)
+		}
+	}
+	if len(ids) == 0 {
+		return id, false, nil
+	}
+	if len(ids) > 1 && len(name) == 0 {
+		return id, false, fmt.Errorf("%d found, the id or name must be configured", len(ids))
+	}
+	return ids[0], true, nil
+}
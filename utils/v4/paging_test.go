@@ -0,0 +1,182 @@
+package v4
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/chirpstack/chirpstack/api/go/v4/api"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// fakeProfileService returns the profiles matching the search page by page and records the requested offsets
+type fakeProfileService struct {
+	api.UnimplementedDeviceProfileServiceServer
+	names   []string
+	offsets []uint32
+}
+
+func (s *fakeProfileService) List(ctx context.Context, req *api.ListDeviceProfilesRequest) (*api.ListDeviceProfilesResponse, error) {
+	s.offsets = append(s.offsets, req.Offset)
+	var matches []*api.DeviceProfileListItem
+	for i, name := range s.names {
+		if strings.Contains(name, req.Search) {
+			matches = append(matches, &api.DeviceProfileListItem{Id: fmt.Sprintf("profile-%d", i), Name: name})
+		}
+	}
+	return &api.ListDeviceProfilesResponse{TotalCount: uint32(len(matches)), Result: page(matches, req.Offset, req.Limit)}, nil
+}
+
+// fakeDeviceService returns count devices page by page
+type fakeDeviceService struct {
+	api.UnimplementedDeviceServiceServer
+	count int
+}
+
+func (s *fakeDeviceService) List(ctx context.Context, req *api.ListDevicesRequest) (*api.ListDevicesResponse, error) {
+	devices := make([]*api.DeviceListItem, s.count)
+	for i := range devices {
+		devices[i] = &api.DeviceListItem{DevEui: fmt.Sprintf("%016x", i)}
+	}
+	return &api.ListDevicesResponse{TotalCount: uint32(s.count), Result: page(devices, req.Offset, req.Limit)}, nil
+}
+
+func page[T any](items []T, offset uint32, limit uint32) []T {
+	if int(offset) >= len(items) {
+		return nil
+	}
+	end := int(offset + limit)
+	if end > len(items) {
+		end = len(items)
+	}
+	return items[offset:end]
+}
+
+// dialFake connects to an in-memory server of the fake services
+func dialFake(t *testing.T, register func(server *grpc.Server)) *grpc.ClientConn {
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	register(server)
+	go server.Serve(listener) //nolint:errcheck
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial("bufnet", grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestFindProfile(t *testing.T) {
+	// 精确匹配的profile在第三页
+	names := make([]string, 0, 231)
+	for i := 0; i < 230; i++ {
+		names = append(names, fmt.Sprintf("Lora Device %03d", i))
+	}
+	names = append(names, "Lora Device")
+
+	tests := []struct {
+		name    string
+		find    string
+		id      string
+		offsets []uint32
+	}{
+		{"last page", "Lora Device", "profile-230", []uint32{0, 100, 200}},
+		{"first page", "Lora Device 001", "profile-1", []uint32{0}},
+		{"not found", "Lora Device CO2", "", []uint32{0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &fakeProfileService{names: names}
+			conn := dialFake(t, func(server *grpc.Server) { api.RegisterDeviceProfileServiceServer(server, service) })
+
+			id, err := FindProfile(conn, context.Background(), "tenant", tt.find)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if id != tt.id {
+				t.Errorf("expected id %q, got %q", tt.id, id)
+			}
+			if fmt.Sprint(service.offsets) != fmt.Sprint(tt.offsets) {
+				t.Errorf("expected offsets %v, got %v", tt.offsets, service.offsets)
+			}
+		})
+	}
+}
+
+func TestListDevices(t *testing.T) {
+	for _, count := range []int{0, 99, 100, 250} {
+		t.Run(fmt.Sprint(count), func(t *testing.T) {
+			conn := dialFake(t, func(server *grpc.Server) { api.RegisterDeviceServiceServer(server, &fakeDeviceService{count: count}) })
+
+			devices, err := ListDevices(conn, context.Background(), "application")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(devices) != count {
+				t.Fatalf("expected %d devices, got %d", count, len(devices))
+			}
+			for i, device := range devices {
+				if device.DevEui != fmt.Sprintf("%016x", i) {
+					t.Fatalf("expected device %d, got %s", i, device.DevEui)
+				}
+			}
+		})
+	}
+}
+
+// fakeApplicationDeviceService holds the device profile ids of the devices of each application
+type fakeApplicationDeviceService struct {
+	api.UnimplementedDeviceServiceServer
+	profiles map[string][]string
+}
+
+func (s *fakeApplicationDeviceService) List(ctx context.Context, req *api.ListDevicesRequest) (*api.ListDevicesResponse, error) {
+	var devices []*api.DeviceListItem
+	for i, profileId := range s.profiles[req.ApplicationId] {
+		devices = append(devices, &api.DeviceListItem{DevEui: fmt.Sprintf("%016x", i), DeviceProfileId: profileId})
+	}
+	return &api.ListDevicesResponse{TotalCount: uint32(len(devices)), Result: page(devices, req.Offset, req.Limit)}, nil
+}
+
+func TestProfileInUse(t *testing.T) {
+	conn := dialFake(t, func(server *grpc.Server) {
+		api.RegisterApplicationServiceServer(server, &fakeApplicationService{applications: map[string]string{"app-1": "LoRa", "app-2": "Other"}})
+		api.RegisterDeviceServiceServer(server, &fakeApplicationDeviceService{profiles: map[string][]string{
+			"app-1": {"profile-1"},
+			"app-2": {"profile-2", "profile-2"},
+		}})
+	})
+
+	tests := []struct {
+		name      string
+		tenantId  string
+		profileId string
+		expected  bool
+	}{
+		{"used in the application", "tenant-1", "profile-1", true},
+		{"used only in another application", "tenant-1", "profile-2", true},
+		{"unused", "tenant-1", "profile-3", false},
+		{"other tenant", "tenant-2", "profile-1", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			used, err := ProfileInUse(conn, context.Background(), test.tenantId, test.profileId)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if used != test.expected {
+				t.Errorf("expected %v, got %v", test.expected, used)
+			}
+		})
+	}
+}